@@ -0,0 +1,87 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2018, 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+// Package calico provides GO methods for Calico resources
+package calico
+
+import (
+	"fmt"
+	"log"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Calico APIs
+const (
+	apiServerGroupVersion = "projectcalico.org/v3"     // Served by the calico API server, if it is installed
+	crdGroupVersion       = "crd.projectcalico.org/v1" // Custom resources used by calico with the kubernetes datastore
+)
+
+var dynamicClient dynamic.Interface
+var groupVersion = crdGroupVersion // API used to manage the calico resources
+
+// CIDRNotAlignedError - The subnet has host bits set.  Calico only accepts the network address of the subnet
+type CIDRNotAlignedError struct {
+	CIDR    string
+	Network string
+}
+
+func (e *CIDRNotAlignedError) Error() string {
+	return fmt.Sprintf("invalid subnet %s. Change config to use: %s", e.CIDR, e.Network)
+}
+
+// NodeNotFoundError - None of the calico nodes have the IP address
+type NodeNotFoundError struct {
+	NodeIP string
+}
+
+func (e *NodeNotFoundError) Error() string {
+	return fmt.Sprintf("no calico node has the address: %s", e.NodeIP)
+}
+
+// APIError - Calico API call failed.  The kubernetes API error can be checked with the k8s.io/apimachinery errors
+// package (ex: IsForbidden)
+type APIError struct {
+	Operation string // get, list, create, patch or delete
+	Resource  string // ippools or nodes
+	Name      string // Name of the resource, empty for list
+	Err       error
+}
+
+func (e *APIError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("failed to %s calico %s: %v", e.Operation, e.Resource, e.Err)
+	}
+	return fmt.Sprintf("failed to %s calico %s %s: %v", e.Operation, e.Resource, e.Name, e.Err)
+}
+
+// Unwrap - Underlying kubernetes API error
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Initialize - Select the API used to manage the calico resources.  The calico API server validates the resources
+// and fills in the defaults, so it is used if it is installed
+func Initialize(client *kubernetes.Clientset, dynClient dynamic.Interface) {
+	dynamicClient = dynClient
+	groupVersion = crdGroupVersion
+	if _, err := client.Discovery().ServerResourcesForGroupVersion(apiServerGroupVersion); err == nil {
+		groupVersion = apiServerGroupVersion
+	}
+	log.Printf("Managing calico resources using: %s", groupVersion)
+}
+
+// resource - Calico resource in the selected API
+func resource(name string) schema.GroupVersionResource {
+	gv, _ := schema.ParseGroupVersion(groupVersion) // #nosec G104 group versions are compile time constants
+	return gv.WithResource(name)
+}
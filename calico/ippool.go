@@ -0,0 +1,171 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2018, 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package calico
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// IP in IP and VXLAN modes of the IPPool resources
+const (
+	IPIPModeCrossSubnet = "CrossSubnet"
+	IPIPModeNever       = "Never"
+	VXLANModeNever      = "Never"
+)
+
+// Default block sizes of the IPPool resources
+const (
+	defaultBlockSizeIPv4 int64 = 26
+	defaultBlockSizeIPv6 int64 = 122
+)
+
+// Encapsulation of the traffic between the worker nodes, used by the enabled IPPool resources
+const (
+	EncapsulationNone  = ""
+	EncapsulationIPIP  = "IPIP"
+	EncapsulationVXLAN = "VXLAN"
+)
+
+const ipPoolResource = "ippools"
+
+// ValidateCIDR - Verify that the subnet is a valid CIDR and is the network address of the subnet
+func ValidateCIDR(subnet string) error {
+	_, network, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return fmt.Errorf("invalid subnet %s: %w", subnet, err)
+	}
+	if network.String() != subnet {
+		return &CIDRNotAlignedError{CIDR: subnet, Network: network.String()}
+	}
+	return nil
+}
+
+// ipPoolName - Name of the IPPool created by this VPN pod for the subnet: the subnet followed by the unique suffix
+// of the pod name, so a new VPN pod does not delete the pool of the pod it replaces
+func ipPoolName(subnet string) string {
+	hostname := os.Getenv("HOSTNAME")
+	suffix := hostname[strings.LastIndex(hostname, "-")+1:]
+	return strings.NewReplacer(".", "-", "/", "-", ":", "-").Replace(subnet) + "-" + suffix
+}
+
+// CreateIPPool - Create the calico IPPool resource for the specified subnet.  The pool is disabled, so no pod IPs are
+// assigned from it, and calico does not NAT the traffic that pods send to the subnet.  Pools for the same subnet that
+// were created by other VPN pods are replaced.  If the pool already exists, its spec is updated
+func CreateIPPool(subnet, ipipMode string) error {
+	if err := ValidateCIDR(subnet); err != nil {
+		return err
+	}
+	name := ipPoolName(subnet)
+	spec := map[string]interface{}{"cidr": subnet, "disabled": true, "ipipMode": ipipMode, "natOutgoing": false}
+	if groupVersion == crdGroupVersion {
+		// The custom resources are not defaulted like the calico API server (and calicoctl) do
+		spec["blockSize"] = defaultBlockSizeIPv4
+		if strings.Contains(subnet, ":") {
+			spec["blockSize"] = defaultBlockSizeIPv6
+		}
+		spec["nodeSelector"] = "all()"
+		spec["vxlanMode"] = VXLANModeNever
+	}
+	pools, err := dynamicClient.Resource(resource(ipPoolResource)).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return &APIError{Operation: "list", Resource: ipPoolResource, Err: err}
+	}
+	exists := false
+	for _, pool := range pools.Items {
+		cidr, _, _ := unstructured.NestedString(pool.Object, "spec", "cidr")
+		if pool.GetName() == name {
+			exists = true
+		} else if cidr == subnet {
+			log.Printf("Deleting IPPool %s, it is using the subnet %s", pool.GetName(), subnet)
+			if err := deleteIPPool(pool.GetName()); err != nil {
+				return err
+			}
+		}
+	}
+	if exists {
+		return UpdateIPPool(name, spec)
+	}
+	pool := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": groupVersion,
+		"kind":       "IPPool",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       spec,
+	}}
+	if _, err := dynamicClient.Resource(resource(ipPoolResource)).Create(context.TODO(), pool, metav1.CreateOptions{}); err != nil {
+		return &APIError{Operation: "create", Resource: ipPoolResource, Name: name, Err: err}
+	}
+	log.Printf("ippool.projectcalico.org/%s created", name)
+	return nil
+}
+
+// GetEncapsulation - Determine the encapsulation used by the enabled IPPools of the cluster.  The disabled pools, like
+// the ones created for the VPN subnets, are not used for pod IPs and are ignored.  VXLAN takes precedence over IP in IP
+func GetEncapsulation() (string, error) {
+	pools, err := dynamicClient.Resource(resource(ipPoolResource)).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return "", &APIError{Operation: "list", Resource: ipPoolResource, Err: err}
+	}
+	encapsulation := EncapsulationNone
+	for _, pool := range pools.Items {
+		if disabled, _, _ := unstructured.NestedBool(pool.Object, "spec", "disabled"); disabled {
+			continue
+		}
+		if mode, _, _ := unstructured.NestedString(pool.Object, "spec", "vxlanMode"); mode != "" && mode != VXLANModeNever {
+			return EncapsulationVXLAN, nil
+		}
+		if mode, _, _ := unstructured.NestedString(pool.Object, "spec", "ipipMode"); mode != "" && mode != IPIPModeNever {
+			encapsulation = EncapsulationIPIP
+		}
+	}
+	return encapsulation, nil
+}
+
+// UpdateIPPool - Update only the specified fields of the spec of the IPPool, the other fields are not changed
+func UpdateIPPool(name string, fields map[string]interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{"spec": fields})
+	if err != nil {
+		return err
+	}
+	if _, err := dynamicClient.Resource(resource(ipPoolResource)).Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return &APIError{Operation: "patch", Resource: ipPoolResource, Name: name, Err: err}
+	}
+	log.Printf("ippool.projectcalico.org/%s configured", name)
+	return nil
+}
+
+// DeleteIPPool - Delete the calico IPPool resource created by this VPN pod for the specified subnet.  It is not an
+// error if the pool does not exist
+func DeleteIPPool(subnet string) error {
+	return deleteIPPool(ipPoolName(subnet))
+}
+
+func deleteIPPool(name string) error {
+	err := dynamicClient.Resource(resource(ipPoolResource)).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return &APIError{Operation: "delete", Resource: ipPoolResource, Name: name, Err: err}
+	}
+	log.Printf("ippool.projectcalico.org/%s deleted", name)
+	return nil
+}
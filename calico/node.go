@@ -0,0 +1,71 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2018, 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package calico
+
+import (
+	"context"
+	"crypto/sha1" // #nosec G505 the hash is only used to derive the interface name, same as the calico CNI plugin
+	"encoding/hex"
+	"net"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	nodeResource = "nodes"
+
+	podInterfacePrefix = "cali" // Default interface prefix of the calico CNI plugin
+)
+
+// GetNodeSubnet - Get the subnet for the node IP that was specified, from the addresses of the calico node resources
+func GetNodeSubnet(workerIP string) (string, error) {
+	nodes, err := dynamicClient.Resource(resource(nodeResource)).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return "", &APIError{Operation: "list", Resource: nodeResource, Err: err}
+	}
+	for _, node := range nodes.Items {
+		for _, address := range nodeAddresses(node) {
+			ip, network, err := net.ParseCIDR(address)
+			if err == nil && ip.String() == workerIP {
+				return network.String(), nil
+			}
+		}
+	}
+	return "", &NodeNotFoundError{NodeIP: workerIP}
+}
+
+// nodeAddresses - Addresses of the calico node in CIDR format: the BGP addresses and the addresses detected by
+// calico-node (also set when BGP is not used)
+func nodeAddresses(node unstructured.Unstructured) []string {
+	addresses := []string{}
+	for _, field := range []string{"ipv4Address", "ipv6Address"} {
+		if address, _, _ := unstructured.NestedString(node.Object, "spec", "bgp", field); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	list, _, _ := unstructured.NestedSlice(node.Object, "spec", "addresses")
+	for _, item := range list {
+		if entry, ok := item.(map[string]interface{}); ok {
+			if address, ok := entry["address"].(string); ok {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses
+}
+
+// GetPodInterface - Get the cali* interface name for the pod.  The calico CNI plugin names the host side of the veth
+// pair after the SHA1 hash of "<namespace>.<pod name>"
+func GetPodInterface(namespace, podName string) string {
+	hash := sha1.Sum([]byte(namespace + "." + podName)) // #nosec G401 not used for security
+	return podInterfacePrefix + hex.EncodeToString(hash[:])[:11]
+}
@@ -0,0 +1,71 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package cni
+
+import (
+	"github.com/IBM-Cloud/iks-strongswan/calico"
+)
+
+// Calico resources and devices
+const (
+	calicoGroup           = "crd.projectcalico.org/v1"
+	calicoIPIPDevice      = "tunl0"
+	calicoVXLANDevice     = "vxlan.calico"
+	calicoWireGuardDevice = "wireguard.cali"
+)
+
+// calicoCNI - Calico pod network.  Pods are attached with cali* interfaces and the SNAT of traffic to the remote
+// subnets is disabled with a disabled IPPool for each subnet
+type calicoCNI struct {
+	mode string // CNICalicoIPIP, CNICalicoVXLAN or CNICalicoWireGuard
+}
+
+func (c calicoCNI) Name() string {
+	return c.mode
+}
+
+func (c calicoCNI) NodeSubnet(nodeIP string) (string, error) {
+	return calico.GetNodeSubnet(nodeIP)
+}
+
+func (c calicoCNI) PodInterface(namespace, podName string) (string, error) {
+	if err := enableIPForwarding(); err != nil {
+		return "", err
+	}
+	return calico.GetPodInterface(namespace, podName), nil
+}
+
+// The IPPool of the subnet is created with IP in IP encapsulation only when Calico is using it
+func (c calicoCNI) DisableSNAT(subnet string) error {
+	ipipMode := calico.IPIPModeNever
+	if c.mode == CNICalicoIPIP {
+		ipipMode = calico.IPIPModeCrossSubnet
+	}
+	return calico.CreateIPPool(subnet, ipipMode)
+}
+
+func (c calicoCNI) RestoreSNAT(subnet string) error {
+	return calico.DeleteIPPool(subnet)
+}
+
+// With WireGuard, the traffic to the other worker nodes is routed by a separate routing table, so the main routing
+// table does not have overlay routes.  Without encapsulation, the worker nodes are reached directly
+func (c calicoCNI) OverlayDevice() string {
+	switch c.mode {
+	case CNICalicoVXLAN:
+		return calicoVXLANDevice
+	case CNICalicoWireGuard:
+		return calicoWireGuardDevice
+	case CNICalicoBGP:
+		return ""
+	}
+	return calicoIPIPDevice
+}
@@ -0,0 +1,142 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+// Package cni provides GO methods for the pod network of the cluster
+package cni
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/IBM-Cloud/iks-strongswan/calico"
+	"github.com/IBM-Cloud/iks-strongswan/kube"
+)
+
+// Supported CNI plugins
+const (
+	CNIAuto            = "auto"             // Detect the CNI plugin from the cluster and the node
+	CNICalicoIPIP      = "calico-ipip"      // Calico with IP in IP encapsulation between subnets
+	CNICalicoVXLAN     = "calico-vxlan"     // Calico with VXLAN encapsulation
+	CNICalicoWireGuard = "calico-wireguard" // Calico with WireGuard encryption between the worker nodes
+	CNICalicoBGP       = "calico-bgp"       // Calico without encapsulation, the pod routes are exchanged with BGP
+	CNIGeneric         = "generic"          // Pods attached to the node with veth pairs (ex: bridge, flannel)
+)
+
+// CNI - Pod network operations that the VPN pod and route daemon depend on
+type CNI interface {
+	// Name of the CNI plugin
+	Name() string
+	// NodeSubnet - Subnet of the private IP of the worker node
+	NodeSubnet(nodeIP string) (string, error)
+	// PodInterface - Host side interface of the VPN pod.  Empty if the route daemon must look it up on the node
+	PodInterface(namespace, podName string) (string, error)
+	// DisableSNAT - Do not SNAT the traffic that pods send to the subnet, so it reaches the VPN with the pod IP
+	DisableSNAT(subnet string) error
+	// RestoreSNAT - Undo DisableSNAT when the VPN pod is stopped
+	RestoreSNAT(subnet string) error
+	// OverlayDevice - Device that encapsulates the traffic to pods on worker nodes in other subnets.  Empty if the
+	// pod network does not use one
+	OverlayDevice() string
+}
+
+const ipForwardFile = "/proc/sys/net/ipv4/ip_forward"
+
+var plugin CNI = calicoCNI{mode: CNICalicoIPIP}
+
+// Init - Select the CNI plugin.  With CNIAuto, Calico is used if its API group is served by the cluster.  The
+// encapsulation of Calico is detected from the devices on the node when the process runs in the network namespace
+// of the node (route daemon).  Otherwise (VPN pod) it is detected from the IPPools of the cluster
+func Init(client *kubernetes.Clientset, name string, nodeNetwork bool) error {
+	switch name {
+	case "", CNIAuto:
+		if _, err := client.Discovery().ServerResourcesForGroupVersion(calicoGroup); err != nil {
+			log.Printf("Calico API %s is not served by the cluster, using the %s pod network: %v", calicoGroup, CNIGeneric, err)
+			plugin = genericCNI{}
+			return nil
+		}
+		calico.Initialize(client, kube.GetDynamicClient())
+		if nodeNetwork {
+			plugin = detectDevice()
+			return nil
+		}
+		detected, err := detectIPPools()
+		if err != nil {
+			return err
+		}
+		plugin = detected
+		return nil
+	case CNICalicoIPIP, CNICalicoVXLAN, CNICalicoWireGuard, CNICalicoBGP:
+		plugin = calicoCNI{mode: name}
+	case CNIGeneric:
+		plugin = genericCNI{}
+		return nil
+	default:
+		return fmt.Errorf("unknown CNI plugin: %s", name)
+	}
+	calico.Initialize(client, kube.GetDynamicClient())
+	return nil
+}
+
+// Get - CNI plugin selected by Init
+func Get() CNI {
+	return plugin
+}
+
+// detectDevice - Determine the encapsulation of Calico from the overlay devices on the node
+func detectDevice() CNI {
+	switch {
+	case deviceExists(calicoVXLANDevice):
+		return calicoCNI{mode: CNICalicoVXLAN}
+	case deviceExists(calicoWireGuardDevice):
+		return calicoCNI{mode: CNICalicoWireGuard}
+	}
+	return calicoCNI{mode: CNICalicoIPIP}
+}
+
+// detectIPPools - Determine the encapsulation of Calico from the IPPools.  The VPN pod only uses the mode to create
+// the IPPools of the VPN subnets, so WireGuard without encapsulation is reported as CNICalicoBGP
+func detectIPPools() (CNI, error) {
+	encapsulation, err := calico.GetEncapsulation()
+	if err != nil {
+		return nil, fmt.Errorf("unable to detect the encapsulation of the calico pod network: %v", err)
+	}
+	switch encapsulation {
+	case calico.EncapsulationVXLAN:
+		return calicoCNI{mode: CNICalicoVXLAN}, nil
+	case calico.EncapsulationIPIP:
+		return calicoCNI{mode: CNICalicoIPIP}, nil
+	}
+	return calicoCNI{mode: CNICalicoBGP}, nil
+}
+
+// deviceExists - Is the network device defined in the network namespace of the process
+func deviceExists(name string) bool {
+	_, err := net.InterfaceByName(name)
+	return err == nil
+}
+
+// enableIPForwarding - The VPN pod forwards the traffic between the pods and the tunnel
+func enableIPForwarding() error {
+	value, err := os.ReadFile(ipForwardFile)
+	if err == nil && strings.TrimSpace(string(value)) == "1" {
+		return nil
+	}
+	outBytes, err := exec.Command("sudo", "/sbin/sysctl", "-w", "net.ipv4.ip_forward=1").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to enable IP forwarding, set 'privilegedVpnPod' to 'true' in the helm configuration: %v - %s", err, strings.TrimSpace(string(outBytes)))
+	}
+	return nil
+}
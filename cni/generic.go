@@ -0,0 +1,64 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package cni
+
+import (
+	"log"
+	"net"
+)
+
+// genericCNI - Pods are attached to the worker node with veth pairs, either routed directly or through a bridge.
+// The name of the host side interface is not visible from inside of the VPN pod, so the route daemon looks up the
+// device of the route to the VPN pod on the node
+type genericCNI struct{}
+
+func (c genericCNI) Name() string {
+	return CNIGeneric
+}
+
+// The subnet is read from the interface that holds the node IP.  The VPN pod is not in the network namespace of the
+// node, so it uses the node IP as a host subnet until the route daemon on the node publishes the subnet
+func (c genericCNI) NodeSubnet(nodeIP string) (string, error) {
+	ip := net.ParseIP(nodeIP)
+	addrs, err := net.InterfaceAddrs()
+	if err == nil && ip != nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				_, subnet, _ := net.ParseCIDR(ipNet.String())
+				return subnet.String(), nil
+			}
+		}
+	}
+	hostSubnet := nodeIP + "/32"
+	if ip != nil && ip.To4() == nil {
+		hostSubnet = nodeIP + "/128"
+	}
+	log.Printf("Subnet of worker node %s is not known, using %s until the route daemon on the node reports it", nodeIP, hostSubnet)
+	return hostSubnet, nil
+}
+
+func (c genericCNI) PodInterface(namespace, podName string) (string, error) {
+	return "", enableIPForwarding()
+}
+
+// The SNAT rules of the pod network are not known
+func (c genericCNI) DisableSNAT(subnet string) error {
+	log.Printf("WARNING: Pod network %s does not support disabling the SNAT of pod traffic to %s", CNIGeneric, subnet)
+	return nil
+}
+
+func (c genericCNI) RestoreSNAT(subnet string) error {
+	return nil
+}
+
+func (c genericCNI) OverlayDevice() string {
+	return ""
+}
@@ -3,6 +3,8 @@ module github.com/IBM-Cloud/iks-strongswan
 go 1.25.5
 
 require (
+	github.com/vishvananda/netlink v1.3.1
+	github.com/vishvananda/netns v0.0.5
 	gopkg.in/yaml.v2 v2.4.0
 	k8s.io/api v0.34.3
 	k8s.io/apimachinery v0.34.3
@@ -73,7 +73,7 @@ Determine value that should used if connectUsingLoadBalancerIP==auto
 */}}
 {{- define "strongswan.connectUsingLoadBalancerIP" -}}
     {{- if eq .Values.connectUsingLoadBalancerIP "auto" -}}
-        {{- if and (eq .Values.ipsec.auto "start") (or .Values.loadBalancerIP .Values.zoneLoadBalancer) -}}
+        {{- if and (eq .Values.ipsec.auto "start") (or .Values.loadBalancerIP .Values.zoneLoadBalancer (eq .Values.exposure "externalIP")) -}}
             {{- "true" -}}
         {{- else -}}
             {{- "false" -}}
@@ -14,18 +14,17 @@ import (
 	"log"
 	"os"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc" // Needed for armada
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-// GetClient - Create client connection to kubernetes master
-func GetClient() *kubernetes.Clientset {
+// Create the client config
+func getConfig() *rest.Config {
 	var config *rest.Config
 	var err error
-
-	// Create the client config
 	kubeConfig := os.Getenv("KUBECONFIG")
 	if kubeConfig == "" {
 		config, err = rest.InClusterConfig()
@@ -34,13 +33,25 @@ func GetClient() *kubernetes.Clientset {
 	}
 	if err != nil {
 		log.Fatalf("ERROR: Failed to get config: %v", err)
-		return nil
 	}
+	return config
+}
 
-	// Create the client
-	client, err := kubernetes.NewForConfig(config)
+// GetClient - Create client connection to kubernetes master
+func GetClient() *kubernetes.Clientset {
+	client, err := kubernetes.NewForConfig(getConfig())
 	if err != nil {
 		log.Fatalf("ERROR: Failed to get client: %v", err)
 	}
 	return client
 }
+
+// GetDynamicClient - Create client connection to kubernetes master for resources that are not part of the client set
+// (ex: calico resources)
+func GetDynamicClient() dynamic.Interface {
+	client, err := dynamic.NewForConfig(getConfig())
+	if err != nil {
+		log.Fatalf("ERROR: Failed to get dynamic client: %v", err)
+	}
+	return client
+}
@@ -2,7 +2,7 @@
  * IBM Confidential
  * OCO Source Materials
  * IBM Cloud Kubernetes Service, 5737-D43
- * (C) Copyright IBM Corp. 2017, 2024 All Rights Reserved.
+ * (C) Copyright IBM Corp. 2017, 2026 All Rights Reserved.
  * The source code for this program is not published or otherwise divested of
  * its trade secrets, irrespective of what has been deposited with
  * the U.S. Copyright Office.
@@ -14,8 +14,10 @@ package kube
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"sort"
+	"strconv"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
@@ -27,36 +29,80 @@ import (
 
 // RouteData - routing data that to be stored in the config map
 type RouteData struct {
-	ConnectUsingLB string // Connect VPN using LB VIP
-	LoadBalancerIP string // Load balancer IP address
-	LocalSubnet    string // Local subnets to add routes for
-	RemoteGateway  string // Remove gateway
-	RemoteSubnet   string // Remove subnets to add routes for
-	RouteTable     string // Routing table to use (222-235)
-	VpnPodDevice   string // VPN pod Interface name
-	VpnPodIP       string // VPN pod IP address
-	VpnPodName     string // VPN pod name
-	WorkerNodeIP   string // Worker node IP address
-	WorkerSubnet   string // Worker subnet
+	ConnectUsingLB   string // Connect VPN using LB VIP
+	Generation       string // Incremented each time the VPN pod updates the config map
+	LoadBalancerIP   string // Load balancer IP address
+	LoadBalancerIPv6 string // Load balancer IPv6 address, used when the remote gateway is an IPv6 address
+	LocalSubnet      string // Local subnets to add routes for
+	LocalSubnetNAT   string // localSubnetNAT rules of the release
+	NonClusterSubnet string // localNonClusterSubnet subnets of the release
+	RemoteGateway    string // Remove gateway
+	RemoteSubnet     string // Remove subnets to add routes for
+	RemoteSubnetNAT  string // remoteSubnetNAT rules of the release
+	RouteNamespaces  string // Only pods in these namespaces use the VPN (empty = all namespaces)
+	RoutePodSelector string // Only pods matching this label selector use the VPN (empty = all pods)
+	RouteTable       string // Routing table to use (200-215)
+	RulePriority     string // Priority of the "from all" rule for the routing table
+	TunnelGroup      string // Releases in the same tunnel group are redundant tunnels to the same remote subnets
+	TunnelGroupMode  string // How the routes of a tunnel group are spread over the tunnels: failover or ecmp
+	TunnelPriority   string // Preference of the tunnel within its group, lowest value is preferred
+	TunnelState      string // Health of the tunnel, based on the IKE SA and the monitoring probes: up or down
+	TunnelTable      string // Routing table used for the tunl0 routes on the VPN worker node
+	TunnelWeight     string // Relative share of the traffic sent over the tunnel with ecmp
+	VpnPodDevice     string // VPN pod Interface name
+	VpnPodIP         string // VPN pod IP address
+	VpnPodIPv6       string // VPN pod IPv6 address, used to route the IPv6 remote subnets
+	VpnPodName       string // VPN pod name
+	WorkerNodeIP     string // Worker node IP address
+	WorkerNodeIPv6   string // Worker node IPv6 address, used to route the IPv6 remote subnets
+	WorkerSubnet     string // Worker subnet
+	Zone             string // Zone of the VPN worker node
+	ZoneLocalRouting string // Route daemons prefer the tunnel of the tunnel group that is in their own zone
 }
 
 type clusterInfo struct {
 	ClusterID string `json:"cluster_id"`
 }
 
-// List of validation types - used when calling validateValueIsCorrect()
+// Keys of the version 1 (legacy) route data, also used as the field names of the version 2 document
 const (
-	keyConnectUsingLB = "connectUsingLB"
-	keyLoadBalancerIP = "loadBalancerIP"
-	keyLocalSubnet    = "localSubnet"
-	keyRemoteGateway  = "remoteGateway"
-	keyRemoteSubnet   = "remoteSubnet"
-	keyRouteTable     = "routeTable"
-	keyVpnPodDevice   = "vpnPodDevice"
-	keyVpnPodIP       = "vpnPodIP"
-	keyVpnPodName     = "vpnPodName"
-	keyWorkerNodeIP   = "workerNodeIP"
-	keyWorkerSubnet   = "workerSubnet"
+	keyConnectUsingLB   = "connectUsingLB"
+	keyGeneration       = "generation"
+	keyLoadBalancerIP   = "loadBalancerIP"
+	keyLoadBalancerIPv6 = "loadBalancerIPv6"
+	keyLocalSubnet      = "localSubnet"
+	keyLocalSubnetNAT   = "localSubnetNAT"
+	keyNonClusterSubnet = "localNonClusterSubnet"
+	keyRemoteGateway    = "remoteGateway"
+	keyRemoteSubnet     = "remoteSubnet"
+	keyRemoteSubnetNAT  = "remoteSubnetNAT"
+	keyRouteNamespaces  = "routeNamespaces"
+	keyRoutePodSelector = "routePodSelector"
+	keyRouteTable       = "routeTable"
+	keyRulePriority     = "rulePriority"
+	keyTunnelGroup      = "tunnelGroup"
+	keyTunnelGroupMode  = "tunnelGroupMode"
+	keyTunnelPriority   = "tunnelPriority"
+	keyTunnelState      = "tunnelState"
+	keyTunnelTable      = "tunnelTable"
+	keyTunnelWeight     = "tunnelWeight"
+	keyVpnPodDevice     = "vpnPodDevice"
+	keyVpnPodIP         = "vpnPodIP"
+	keyVpnPodIPv6       = "vpnPodIPv6"
+	keyVpnPodName       = "vpnPodName"
+	keyWorkerNodeIP     = "workerNodeIP"
+	keyWorkerNodeIPv6   = "workerNodeIPv6"
+	keyWorkerSubnet     = "workerSubnet"
+	keyZone             = "zone"
+	keyZoneLocalRouting = "zoneLocalRouting"
+)
+
+// Tunnel group modes and tunnel states
+const (
+	TunnelGroupFailover = "failover" // Only the preferred tunnel that is up carries the traffic
+	TunnelGroupECMP     = "ecmp"     // Traffic is spread over all of the tunnels that are up, based on their weight
+	TunnelStateUp       = "up"
+	TunnelStateDown     = "down"
 )
 
 // Retrieve an existing config map
@@ -64,6 +110,20 @@ func getConfigMap(client *kubernetes.Clientset, namespace, configMapName string)
 	return client.CoreV1().ConfigMaps(namespace).Get(context.TODO(), configMapName, metav1.GetOptions{})
 }
 
+// GetConfigMapData - Retrieve the data of a config map, retrying transient errors
+func GetConfigMapData(client *kubernetes.Clientset, namespace, configMapName string) (map[string]string, error) {
+	var data map[string]string
+	err := retry(fmt.Sprintf("get config map %s/%s", namespace, configMapName), func() error {
+		configMap, err := getConfigMap(client, namespace, configMapName)
+		if err != nil {
+			return err
+		}
+		data = configMap.Data
+		return nil
+	})
+	return data, err
+}
+
 // GetClusterID - Retrieve Cluster Id (if it exists) for IKS environments
 func GetClusterID(client *kubernetes.Clientset) (clusterID string) {
 	configMap, err := getConfigMap(client, "kube-system", "cluster-info")
@@ -82,20 +142,38 @@ func GetClusterID(client *kubernetes.Clientset) (clusterID string) {
 	return clusterData.ClusterID
 }
 
-// MapToRouteData - Extract from data map[] and return RouteData struct
-func MapToRouteData(mapData map[string]string) RouteData {
+// legacyMapToRouteData - Extract the version 1 route data, stored as one config map key per field
+func legacyMapToRouteData(mapData map[string]string) RouteData {
 	routeData := RouteData{
-		ConnectUsingLB: mapData[keyConnectUsingLB],
-		LoadBalancerIP: mapData[keyLoadBalancerIP],
-		LocalSubnet:    mapData[keyLocalSubnet],
-		RemoteGateway:  mapData[keyRemoteGateway],
-		RemoteSubnet:   mapData[keyRemoteSubnet],
-		RouteTable:     mapData[keyRouteTable],
-		VpnPodDevice:   mapData[keyVpnPodDevice],
-		VpnPodIP:       mapData[keyVpnPodIP],
-		VpnPodName:     mapData[keyVpnPodName],
-		WorkerNodeIP:   mapData[keyWorkerNodeIP],
-		WorkerSubnet:   mapData[keyWorkerSubnet],
+		ConnectUsingLB:   mapData[keyConnectUsingLB],
+		Generation:       mapData[keyGeneration],
+		LoadBalancerIP:   mapData[keyLoadBalancerIP],
+		LoadBalancerIPv6: mapData[keyLoadBalancerIPv6],
+		LocalSubnet:      mapData[keyLocalSubnet],
+		LocalSubnetNAT:   mapData[keyLocalSubnetNAT],
+		NonClusterSubnet: mapData[keyNonClusterSubnet],
+		RemoteGateway:    mapData[keyRemoteGateway],
+		RemoteSubnet:     mapData[keyRemoteSubnet],
+		RemoteSubnetNAT:  mapData[keyRemoteSubnetNAT],
+		RouteNamespaces:  mapData[keyRouteNamespaces],
+		RoutePodSelector: mapData[keyRoutePodSelector],
+		RouteTable:       mapData[keyRouteTable],
+		RulePriority:     mapData[keyRulePriority],
+		TunnelGroup:      mapData[keyTunnelGroup],
+		TunnelGroupMode:  mapData[keyTunnelGroupMode],
+		TunnelPriority:   mapData[keyTunnelPriority],
+		TunnelState:      mapData[keyTunnelState],
+		TunnelTable:      mapData[keyTunnelTable],
+		TunnelWeight:     mapData[keyTunnelWeight],
+		VpnPodDevice:     mapData[keyVpnPodDevice],
+		VpnPodIP:         mapData[keyVpnPodIP],
+		VpnPodIPv6:       mapData[keyVpnPodIPv6],
+		VpnPodName:       mapData[keyVpnPodName],
+		WorkerNodeIP:     mapData[keyWorkerNodeIP],
+		WorkerNodeIPv6:   mapData[keyWorkerNodeIPv6],
+		WorkerSubnet:     mapData[keyWorkerSubnet],
+		Zone:             mapData[keyZone],
+		ZoneLocalRouting: mapData[keyZoneLocalRouting],
 	}
 	return routeData
 }
@@ -115,42 +193,109 @@ func MapToSortedString(mapData map[string]string) string {
 	return buffer
 }
 
-// RouteDataToMap - Extract from data map[] and return RouteData struct
-func RouteDataToMap(routeData RouteData) map[string]string {
+// legacyRouteDataToMap - Build the version 1 route data, one key per field
+func legacyRouteDataToMap(routeData RouteData) map[string]string {
 	dataMap := map[string]string{}
 	dataMap[keyConnectUsingLB] = routeData.ConnectUsingLB
+	dataMap[keyGeneration] = routeData.Generation
 	dataMap[keyLoadBalancerIP] = routeData.LoadBalancerIP
+	dataMap[keyLoadBalancerIPv6] = routeData.LoadBalancerIPv6
 	dataMap[keyLocalSubnet] = routeData.LocalSubnet
+	dataMap[keyLocalSubnetNAT] = routeData.LocalSubnetNAT
+	dataMap[keyNonClusterSubnet] = routeData.NonClusterSubnet
 	dataMap[keyRemoteGateway] = routeData.RemoteGateway
 	dataMap[keyRemoteSubnet] = routeData.RemoteSubnet
+	dataMap[keyRemoteSubnetNAT] = routeData.RemoteSubnetNAT
+	dataMap[keyRouteNamespaces] = routeData.RouteNamespaces
+	dataMap[keyRoutePodSelector] = routeData.RoutePodSelector
 	dataMap[keyRouteTable] = routeData.RouteTable
+	dataMap[keyRulePriority] = routeData.RulePriority
+	dataMap[keyTunnelGroup] = routeData.TunnelGroup
+	dataMap[keyTunnelGroupMode] = routeData.TunnelGroupMode
+	dataMap[keyTunnelPriority] = routeData.TunnelPriority
+	dataMap[keyTunnelState] = routeData.TunnelState
+	dataMap[keyTunnelTable] = routeData.TunnelTable
+	dataMap[keyTunnelWeight] = routeData.TunnelWeight
 	dataMap[keyVpnPodDevice] = routeData.VpnPodDevice
 	dataMap[keyVpnPodIP] = routeData.VpnPodIP
+	dataMap[keyVpnPodIPv6] = routeData.VpnPodIPv6
 	dataMap[keyVpnPodName] = routeData.VpnPodName
 	dataMap[keyWorkerNodeIP] = routeData.WorkerNodeIP
+	dataMap[keyWorkerNodeIPv6] = routeData.WorkerNodeIPv6
 	dataMap[keyWorkerSubnet] = routeData.WorkerSubnet
+	dataMap[keyZone] = routeData.Zone
+	dataMap[keyZoneLocalRouting] = routeData.ZoneLocalRouting
 	return dataMap
 }
 
-// UpdateConfigMap - Update the config map with the specified routing data
-func UpdateConfigMap(client *kubernetes.Clientset, namespace, configMapName string, routeData RouteData) {
-	cm, err := getConfigMap(client, namespace, configMapName)
+// RouteDataString - Convert the routing data to a sorted key/value string, ignoring the generation.  Used to
+// determine if the routes changed.  The data is normalized, so the same routes stored in different versions of the
+// route data are equal
+func RouteDataString(mapData map[string]string) string {
+	routeData, err := MapToRouteData(mapData)
 	if err != nil {
-		log.Fatalf("ERROR: Failed to retrieve %s/%s: %v", namespace, configMapName, err)
+		return MapToSortedString(mapData)
+	}
+	if document, err := newRouteDocument(routeData); err == nil {
+		routeData = document.routeData()
 	}
-	cm.Data = RouteDataToMap(routeData)
-	log.Printf("   config map data: %v", MapToSortedString(cm.Data))
-	_, err = client.CoreV1().ConfigMaps(namespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
+	routeData.Generation = ""
+	dataMap := map[string]string{}
+	for k, v := range legacyRouteDataToMap(routeData) {
+		if k != keyGeneration {
+			dataMap[k] = v
+		}
+	}
+	return MapToSortedString(dataMap)
+}
+
+// UpdateConfigMap - Update the config map with the specified routing data.  The generation is incremented
+// and returned.  The update is retried if the config map was changed at the same time
+func UpdateConfigMap(client *kubernetes.Clientset, namespace, configMapName string, routeData RouteData) (string, error) {
+	return updateConfigMap(client, namespace, configMapName, routeData, retry)
+}
+
+// UpdateConfigMapOnce - Same as UpdateConfigMap, but the update is only attempted once.  Used by callers that retry
+// the update on their own schedule, so they do not block for the deadline of the retry policy
+func UpdateConfigMapOnce(client *kubernetes.Clientset, namespace, configMapName string, routeData RouteData) (string, error) {
+	return updateConfigMap(client, namespace, configMapName, routeData, func(description string, operation func() error) error {
+		if err := operation(); err != nil {
+			return fmt.Errorf("failed to %s: %w", description, err)
+		}
+		return nil
+	})
+}
+
+// updateConfigMap - Write the route data to the config map, using the retry routine for the API calls
+func updateConfigMap(client *kubernetes.Clientset, namespace, configMapName string, routeData RouteData, retryFunc func(string, func() error) error) (string, error) {
+	err := retryFunc(fmt.Sprintf("update config map %s/%s", namespace, configMapName), func() error {
+		cm, err := getConfigMap(client, namespace, configMapName)
+		if err != nil {
+			return err
+		}
+		generation, _ := strconv.ParseInt(RouteDataGeneration(cm.Data), 10, 64) // #nosec G104 missing / invalid generation starts over at 1
+		routeData.Generation = strconv.FormatInt(generation+1, 10)
+		data, err := RouteDataToMap(routeData)
+		if err != nil {
+			return &PermanentError{Err: err}
+		}
+		cm.Data = data
+		log.Printf("   config map data: %v", cm.Data[keyRouteData])
+		_, err = client.CoreV1().ConfigMaps(namespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
+		return err
+	})
 	if err != nil {
-		log.Fatalf("ERROR: Failed to update/create config map: %v", err)
+		return "", err
 	}
+	return routeData.Generation, nil
 }
 
-// WatchConfigMap - watch for updates to config maps and calls the provided routines
+// WatchConfigMap - watch for updates to config maps and calls the provided routines.  The returned routine reports
+// whether the initial list of config maps has been delivered to addFunc
 func WatchConfigMap(client *kubernetes.Clientset, namespace, configMapName string,
 	addFunc func(obj interface{}),
 	deleteFunc func(obj interface{}),
-	updateFunc func(oldObj, newObj interface{})) {
+	updateFunc func(oldObj, newObj interface{})) func() bool {
 	// Create a watch on configMaps in kube-system
 	log.Print("Create watchList for configMap changes")
 	watchList := cache.NewListWatchFromClient(
@@ -158,7 +303,30 @@ func WatchConfigMap(client *kubernetes.Clientset, namespace, configMapName strin
 		corev1.ResourceConfigMaps.String(),
 		namespace,
 		fields.OneTermEqualSelector("metadata.name", configMapName))
+	return runConfigMapInformer(watchList, addFunc, deleteFunc, updateFunc)
+}
 
+// WatchConfigMapsBySelector - watch for updates to the config maps in all namespaces that match the label selector
+func WatchConfigMapsBySelector(client *kubernetes.Clientset, labelSelector string,
+	addFunc func(obj interface{}),
+	deleteFunc func(obj interface{}),
+	updateFunc func(oldObj, newObj interface{})) func() bool {
+	log.Printf("Create watchList for configMap changes in all namespaces: %s", labelSelector)
+	watchList := cache.NewFilteredListWatchFromClient(
+		client.CoreV1().RESTClient(),
+		corev1.ResourceConfigMaps.String(),
+		metav1.NamespaceAll,
+		func(options *metav1.ListOptions) {
+			options.LabelSelector = labelSelector
+		})
+	return runConfigMapInformer(watchList, addFunc, deleteFunc, updateFunc)
+}
+
+// Start the informer that calls the provided routines for config map add/delete/update events
+func runConfigMapInformer(watchList cache.ListerWatcher,
+	addFunc func(obj interface{}),
+	deleteFunc func(obj interface{}),
+	updateFunc func(oldObj, newObj interface{})) func() bool {
 	// Handler routine for add/delete/update events
 	log.Print("Register handler routines for configMap changes")
 	_, controller := cache.NewInformerWithOptions(cache.InformerOptions{
@@ -173,7 +341,9 @@ func WatchConfigMap(client *kubernetes.Clientset, namespace, configMapName strin
 	})
 
 	stop := make(chan struct{})
-	if controller != nil { // Should only be nil for unit tests
-		go controller.Run(stop)
+	if controller == nil { // Should only be nil for unit tests
+		return func() bool { return true }
 	}
+	go controller.Run(stop)
+	return controller.HasSynced
 }
@@ -0,0 +1,32 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package kube
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetNamespaceUID - Get the UID of the namespace.  The UID of kube-system is commonly used as the cluster ID
+func GetNamespaceUID(client *kubernetes.Clientset, namespace string) (string, error) {
+	uid := ""
+	err := retry("get namespace "+namespace, func() error {
+		ns, err := client.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		uid = string(ns.UID)
+		return nil
+	})
+	return uid, err
+}
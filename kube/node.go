@@ -13,46 +13,174 @@ package kube
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"net"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
-// GetNodePublicIP - Get the public IP of the specified worker node
-func GetNodePublicIP(client *kubernetes.Clientset, nodeIP string) string {
+// Zone label set on the worker nodes of IBM Cloud clusters
+const ibmZoneLabel = "ibm-cloud.kubernetes.io/zone"
 
-	// Get call searches by node name (assumes node name = node IP, not that way on ICP)
-	node, err := client.CoreV1().Nodes().Get(context.TODO(), nodeIP, metav1.GetOptions{})
+// NodeIPSource - Where the public IP of a worker node is read from: a node address of the given type
+// (ex: ExternalIP), a node label or a node annotation
+type NodeIPSource struct {
+	Kind string // address, label or annotation
+	Key  string // Address type, label key or annotation key
+}
+
+// Kinds of NodeIPSource
+const (
+	NodeIPSourceAddress    = "address"
+	NodeIPSourceLabel      = "label"
+	NodeIPSourceAnnotation = "annotation"
+)
+
+// DefaultNodeIPSources - The public IP is the external IP address of the node.  Used when neither the user nor the
+// platform specify the sources
+var DefaultNodeIPSources = []NodeIPSource{{Kind: NodeIPSourceAddress, Key: string(corev1.NodeExternalIP)}}
+
+// ParseNodeIPSources - Parse a comma separated list of "<kind>:<key>" sources.  nil is returned for an empty list
+func ParseNodeIPSources(value string) ([]NodeIPSource, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	sources := []NodeIPSource{}
+	for _, item := range strings.Split(value, ",") {
+		kind, key, found := strings.Cut(strings.TrimSpace(item), ":")
+		kind = strings.ToLower(kind)
+		if !found || key == "" || (kind != NodeIPSourceAddress && kind != NodeIPSourceLabel && kind != NodeIPSourceAnnotation) {
+			return nil, fmt.Errorf("invalid node IP source: %s", item)
+		}
+		sources = append(sources, NodeIPSource{Kind: kind, Key: key})
+	}
+	return sources, nil
+}
+
+// String - Source in the "<kind>:<key>" format
+func (source NodeIPSource) String() string {
+	return source.Kind + ":" + source.Key
+}
+
+// GetNode - Retrieve the worker node, retrying transient errors.  The node is looked up by name if one was specified.
+// Otherwise, or if there is no node with that name, the node that has the IP in its status addresses is returned.
+// Node names are the node IP on IKS, but are host names on most other clusters
+func GetNode(client *kubernetes.Clientset, nodeName, nodeIP string) (*corev1.Node, error) {
+	var node *corev1.Node
+	description := "get node " + nodeName
+	if nodeName == "" {
+		description = "get node with address " + nodeIP
+	}
+	err := retry(description, func() error {
+		var err error
+		if nodeName != "" {
+			node, err = client.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+			if err == nil || !apierrors.IsNotFound(err) || nodeIP == "" {
+				return err
+			}
+		}
+		node, err = findNodeByAddress(client, nodeIP)
+		return err
+	})
+	return node, err
+}
+
+// findNodeByAddress - Search the worker nodes for the one that has the IP in its status addresses
+func findNodeByAddress(client *kubernetes.Clientset, nodeIP string) (*corev1.Node, error) {
+	nodes, err := client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
-		log.Fatalf("ERROR: Failed to get node: %v", err)
+		return nil, err
 	}
+	for i := range nodes.Items {
+		for _, addr := range nodes.Items[i].Status.Addresses {
+			if addr.Address == nodeIP {
+				return &nodes.Items[i], nil
+			}
+		}
+	}
+	return nil, &PermanentError{Err: fmt.Errorf("no worker node has address: %s", nodeIP)}
+}
 
-	// Search the addresses for the external IP
-	for _, addr := range node.Status.Addresses {
-		if addr.Type == corev1.NodeExternalIP {
-			return addr.Address
+// GetNodePublicIP - Get the public IP of the specified worker node.  The sources are checked in order and the first
+// one that is set is returned.  An empty string is returned if none of the sources are set
+func GetNodePublicIP(client *kubernetes.Clientset, nodeName, nodeIP string, sources []NodeIPSource) (string, error) {
+	node, err := GetNode(client, nodeName, nodeIP)
+	if err != nil {
+		return "", err
+	}
+	if len(sources) == 0 {
+		sources = DefaultNodeIPSources
+	}
+	for _, source := range sources {
+		value := ""
+		switch source.Kind {
+		case NodeIPSourceAddress:
+			for _, addr := range node.Status.Addresses {
+				if strings.EqualFold(string(addr.Type), source.Key) {
+					value = addr.Address
+					break
+				}
+			}
+		case NodeIPSourceLabel:
+			value = node.Labels[source.Key]
+		case NodeIPSourceAnnotation:
+			value = node.Annotations[source.Key]
+		}
+		if value != "" {
+			if net.ParseIP(value) == nil {
+				return "", &PermanentError{Err: fmt.Errorf("%s of node %s is not an IP address: %s", source, node.Name, value)}
+			}
+			return value, nil
 		}
 	}
 
-	// Node does not have an external IP
-	return ""
+	// Node does not have a public IP
+	return "", nil
 }
 
-// GetNodeZone - Get the zone of the specified worker node
-func GetNodeZone(client *kubernetes.Clientset, nodeIP string) string {
+// GetNodeZone - Get the zone of the specified worker node.  The IBM Cloud zone label is used if it is set,
+// otherwise the topology label
+func GetNodeZone(client *kubernetes.Clientset, nodeName, nodeIP string) (string, error) {
+	node, err := GetNode(client, nodeName, nodeIP)
+	if err != nil {
+		return "", err
+	}
+	return nodeZone(node, ibmZoneLabel, corev1.LabelTopologyZone)
+}
 
-	// Get call searches by node name (assumes node name = node IP, not that way on ICP)
-	node, err := client.CoreV1().Nodes().Get(context.TODO(), nodeIP, metav1.GetOptions{})
+// GetNodeTopologyZone - Get the zone of the worker node from its topology label.  The IBM Cloud zone label is used
+// if the topology label is not set
+func GetNodeTopologyZone(client *kubernetes.Clientset, nodeName, nodeIP string) (string, error) {
+	node, err := GetNode(client, nodeName, nodeIP)
 	if err != nil {
-		log.Fatalf("ERROR: Failed to get node: %v", err)
+		return "", err
+	}
+	return nodeZone(node, corev1.LabelTopologyZone, ibmZoneLabel)
+}
+
+// nodeZone - Value of the first zone label that is set on the node
+func nodeZone(node *corev1.Node, zoneLabels ...string) (string, error) {
+	for _, label := range zoneLabels {
+		if zone := node.Labels[label]; zone != "" {
+			return zone, nil
+		}
 	}
+	return "", &PermanentError{Err: fmt.Errorf("none of the zone labels %v are set for node: %s", zoneLabels, node.Name)}
+}
 
-	// Search the labels for "ibm-cloud.kubernetes.io/zone"
-	zone := node.Labels["ibm-cloud.kubernetes.io/zone"]
-	if zone == "" {
-		log.Fatalf("ERROR: ibm-cloud.kubernetes.io/zone label is not set for node: %s", nodeIP)
+// GetNodeNames - Get the names of all of the worker nodes in the cluster
+func GetNodeNames(client *kubernetes.Clientset) ([]string, error) {
+	nodes, err := client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(nodes.Items))
+	for _, node := range nodes.Items {
+		names = append(names, node.Name)
 	}
-	return zone
+	return names, nil
 }
@@ -2,7 +2,7 @@
  * IBM Confidential
  * OCO Source Materials
  * IBM Cloud Kubernetes Service, 5737-D43
- * (C) Copyright IBM Corp. 2017, 2020 All Rights Reserved.
+ * (C) Copyright IBM Corp. 2017, 2026 All Rights Reserved.
  * The source code for this program is not published or otherwise divested of
  * its trade secrets, irrespective of what has been deposited with
  * the U.S. Copyright Office.
@@ -13,31 +13,89 @@ package kube
 
 import (
 	"context"
+	"fmt"
 	"log"
-	"time"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
-var podRetryCount = 10
-
-// GetPodInfo - Get the pod IP and worker node IP
-func GetPodInfo(client *kubernetes.Clientset, namespace, podName string) (string, string) {
-	for i := 1; i <= podRetryCount; i++ {
+// GetPodInfo - Get the pod IP and worker node IP.  Waits for the pod to be running, as allowed by the retry policy.
+// If the pod never reaches the running state, the IPs that were assigned to it are returned
+func GetPodInfo(client *kubernetes.Clientset, namespace, podName string) (string, string, error) {
+	var lastPod *corev1.Pod
+	err := retry(fmt.Sprintf("get pod %s/%s", namespace, podName), func() error {
 		pod, err := client.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{})
 		if err != nil {
-			log.Fatalf("ERROR: Failed to locate pod: %v", err)
+			return err
 		}
-		// Verify the pod is in "Running" state.  After 10 tries, ignore the state
-		if pod.Status.Phase == corev1.PodRunning || i == podRetryCount {
-			return pod.Status.PodIP, pod.Status.HostIP
+		lastPod = pod
+		if pod.Status.Phase != corev1.PodRunning {
+			return notReady("pod <%s> has status: %v", podName, pod.Status.Phase)
 		}
-		// Pod is not active yet. Sleep for a second and try again
-		log.Printf("   pod <%s> has status: %v", podName, pod.Status.Phase)
-		time.Sleep(time.Second)
+		return nil
+	})
+	if err != nil && !(isNotReady(err) && lastPod != nil && lastPod.Status.PodIP != "") {
+		return "", "", err
+	}
+	if err != nil {
+		log.Printf("WARNING: %v", err)
+	}
+	return lastPod.Status.PodIP, lastPod.Status.HostIP, nil
+}
+
+// GetPodIPv6Info - Get the IPv6 address of the pod and of its worker node.  Empty strings are returned in a single
+// stack IPv4 cluster
+func GetPodIPv6Info(client *kubernetes.Clientset, namespace, podName string) (string, string, error) {
+	var pod *corev1.Pod
+	err := retry(fmt.Sprintf("get pod %s/%s", namespace, podName), func() error {
+		var err error
+		pod, err = client.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return "", "", err
 	}
-	// Should never get here.  On last loop, we return with first pod found (regardless of the state)
-	return "", ""
+	podIPv6, hostIPv6 := "", ""
+	for _, podIP := range pod.Status.PodIPs {
+		if strings.Contains(podIP.IP, ":") {
+			podIPv6 = podIP.IP
+		}
+	}
+	for _, hostIP := range pod.Status.HostIPs {
+		if strings.Contains(hostIP.IP, ":") {
+			hostIPv6 = hostIP.IP
+		}
+	}
+	return podIPv6, hostIPv6, nil
+}
+
+// WatchPodsOnNode - watch for updates to the pods in all namespaces that are running on the worker node.  The returned
+// routine reports whether the initial list of pods has been delivered to addFunc
+func WatchPodsOnNode(client *kubernetes.Clientset, nodeName string,
+	addFunc func(obj interface{}),
+	deleteFunc func(obj interface{}),
+	updateFunc func(oldObj, newObj interface{})) func() bool {
+	log.Printf("Create watchList for pod changes on worker node: %s", nodeName)
+	watchList := cache.NewListWatchFromClient(
+		client.CoreV1().RESTClient(),
+		corev1.ResourcePods.String(),
+		metav1.NamespaceAll,
+		fields.OneTermEqualSelector("spec.nodeName", nodeName))
+	_, controller := cache.NewInformerWithOptions(cache.InformerOptions{
+		ListerWatcher: watchList,
+		ObjectType:    &corev1.Pod{},
+		ResyncPeriod:  0,
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc:    addFunc,
+			DeleteFunc: deleteFunc,
+			UpdateFunc: updateFunc,
+		},
+	})
+	go controller.Run(make(chan struct{}))
+	return controller.HasSynced
 }
@@ -0,0 +1,176 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Cluster wide registry of the routing tables used by each strongSwan release
+const (
+	RegistryNamespace = "kube-system"
+	RegistryName      = "strongswan-route-tables"
+
+	routeTableFirst  = 200 // Routing tables 200-215 are used for the remote subnet routes
+	routeTableLast   = 215
+	tunnelTableFirst = 184 // Routing tables 184-199 are used for the tunl0 routes.  199 was used before the registry existed
+	tunnelTableLast  = 199
+
+	registryRetryCount = 10
+)
+
+// TableAllocation - Routing tables and rule priority assigned to a single release
+type TableAllocation struct {
+	RouteTable   string `json:"routeTable"`
+	RulePriority string `json:"rulePriority"`
+	TunnelTable  string `json:"tunnelTable"`
+}
+
+// AllocateRouteTables - Reserve the routing tables for the release in the cluster wide registry.  The allocation
+// of the release is reused if it does not conflict with another release.  preferredTable is used if it is free.
+// Updates are done with optimistic concurrency, so two releases starting at the same time do not get the same tables
+func AllocateRouteTables(client *kubernetes.Clientset, namespace, releaseName, preferredTable string) (TableAllocation, error) {
+	owner := namespace + "." + releaseName
+	for i := 1; i <= registryRetryCount; i++ {
+		cm, err := getRegistry(client)
+		if err != nil {
+			return TableAllocation{}, err
+		}
+		allocations := map[string]TableAllocation{}
+		for key, value := range cm.Data {
+			allocation := TableAllocation{}
+			if err := json.Unmarshal([]byte(value), &allocation); err != nil {
+				log.Printf("WARNING: Ignoring invalid route table registry entry %s: %s", key, value)
+				continue
+			}
+			// Entries of releases that were uninstalled are reclaimed
+			if key != owner && isStaleRegistryEntry(client, key) {
+				log.Printf("Reclaiming route tables of uninstalled release %s: %+v", key, allocation)
+				continue
+			}
+			allocations[key] = allocation
+		}
+
+		allocation, found := allocations[owner]
+		delete(allocations, owner)
+		if found {
+			if conflict := findTableConflict(allocations, allocation); conflict != "" {
+				log.Printf("ERROR: Route tables %+v of %s conflict with release %s.  New tables will be allocated", allocation, owner, conflict)
+				found = false
+			}
+		}
+		if !found {
+			allocation, err = chooseTables(allocations, preferredTable)
+			if err != nil {
+				return TableAllocation{}, err
+			}
+		}
+
+		allocations[owner] = allocation
+		cm.Data = map[string]string{}
+		for key, value := range allocations {
+			data, _ := json.Marshal(value) // #nosec G104 marshal of a struct of strings can not fail
+			cm.Data[key] = string(data)
+		}
+		_, err = client.CoreV1().ConfigMaps(RegistryNamespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
+		if err == nil {
+			return allocation, nil
+		}
+		if !errors.IsConflict(err) {
+			return TableAllocation{}, fmt.Errorf("failed to update %s/%s: %v", RegistryNamespace, RegistryName, err)
+		}
+		log.Printf("Route table registry was updated by another release, retrying (%d/%d)", i, registryRetryCount)
+	}
+	return TableAllocation{}, fmt.Errorf("failed to allocate route tables after %d attempts", registryRetryCount)
+}
+
+// getRegistry - Retrieve the registry config map, creating it if it does not exist
+func getRegistry(client *kubernetes.Clientset) (*corev1.ConfigMap, error) {
+	cm, err := getConfigMap(client, RegistryNamespace, RegistryName)
+	if err == nil {
+		return cm, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to retrieve %s/%s: %v", RegistryNamespace, RegistryName, err)
+	}
+	cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: RegistryName, Namespace: RegistryNamespace}}
+	cm, err = client.CoreV1().ConfigMaps(RegistryNamespace).Create(context.TODO(), cm, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		return getConfigMap(client, RegistryNamespace, RegistryName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s/%s: %v", RegistryNamespace, RegistryName, err)
+	}
+	return cm, nil
+}
+
+// isStaleRegistryEntry - Has the release that owns the "<namespace>.<release>" entry been uninstalled
+func isStaleRegistryEntry(client *kubernetes.Clientset, key string) bool {
+	namespace, releaseName, ok := strings.Cut(key, ".")
+	if !ok {
+		return false
+	}
+	_, err := getConfigMap(client, namespace, releaseName+"-strongswan-routes")
+	return errors.IsNotFound(err)
+}
+
+// findTableConflict - Return the release that is already using one of the tables / priority in the allocation
+func findTableConflict(allocations map[string]TableAllocation, allocation TableAllocation) string {
+	for key, other := range allocations {
+		if other.RouteTable == allocation.RouteTable || other.TunnelTable == allocation.TunnelTable || other.RulePriority == allocation.RulePriority {
+			return key
+		}
+	}
+	return ""
+}
+
+// chooseTables - Select a free routing table, rule priority and tunnel table
+func chooseTables(allocations map[string]TableAllocation, preferredTable string) (TableAllocation, error) {
+	used := map[string]bool{}
+	for _, other := range allocations {
+		used[other.RouteTable] = true
+		used[other.RulePriority] = true
+		used[other.TunnelTable] = true
+	}
+	allocation := TableAllocation{}
+	if preferred, err := strconv.Atoi(preferredTable); err == nil && preferred >= routeTableFirst && preferred <= routeTableLast && !used[preferredTable] {
+		allocation.RouteTable = preferredTable
+	} else {
+		allocation.RouteTable = firstFreeTable(used, routeTableFirst, routeTableLast, 1)
+	}
+	allocation.TunnelTable = firstFreeTable(used, tunnelTableLast, tunnelTableFirst, -1)
+	if allocation.RouteTable == "" || allocation.TunnelTable == "" {
+		return TableAllocation{}, fmt.Errorf("no free route tables, %d releases are registered in %s/%s", len(allocations), RegistryNamespace, RegistryName)
+	}
+	// The rule for the routing table uses the table number as its priority
+	allocation.RulePriority = allocation.RouteTable
+	return allocation, nil
+}
+
+// firstFreeTable - Find the first table number between first and last that is not used
+func firstFreeTable(used map[string]bool, first, last, step int) string {
+	for table := first; table != last+step; table += step {
+		if !used[strconv.Itoa(table)] {
+			return strconv.Itoa(table)
+		}
+	}
+	return ""
+}
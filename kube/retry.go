@@ -0,0 +1,154 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package kube
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// RetryPolicy - How the kube helpers retry API calls that fail with a transient error.  The delay between attempts
+// grows exponentially from InitialDelay up to MaxDelay, randomized by +/- Jitter (fraction of the delay).  Retries
+// stop once Deadline has passed since the first attempt
+type RetryPolicy struct {
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	Jitter       float64
+	Deadline     time.Duration
+}
+
+// DefaultRetryPolicy - Retry policy used by the kube helpers unless SetRetryPolicy is called
+var DefaultRetryPolicy = RetryPolicy{
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     10 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+	Deadline:     60 * time.Second,
+}
+
+var retryPolicy = DefaultRetryPolicy
+
+// notReadyError - The resource exists but is not in the expected state yet (ex: pod not running, load balancer IP
+// not assigned).  Always retried
+type notReadyError struct {
+	message string
+}
+
+func (e *notReadyError) Error() string {
+	return e.message
+}
+
+// PermanentError - Error that is not retried.  Returned by the helpers when retrying can not help, for example
+// when a required label is missing
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap - Underlying error
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// SetRetryPolicy - Change the retry policy used by the kube helpers
+func SetRetryPolicy(policy RetryPolicy) {
+	retryPolicy = policy
+}
+
+// GetRetryPolicy - Get the retry policy used by the kube helpers
+func GetRetryPolicy() RetryPolicy {
+	return retryPolicy
+}
+
+// notReady - Create an error for a resource that is not in the expected state yet
+func notReady(format string, args ...interface{}) error {
+	return &notReadyError{message: fmt.Sprintf(format, args...)}
+}
+
+// isNotReady - Did the operation fail because the resource never reached the expected state
+func isNotReady(err error) bool {
+	var target *notReadyError
+	return errors.As(err, &target)
+}
+
+// IsTransientError - Is the error likely to go away if the API call is retried: API server timeouts, throttling,
+// internal errors, update conflicts and connection failures.  Not found, forbidden and invalid requests are permanent
+func IsTransientError(err error) bool {
+	var permanent *PermanentError
+	if err == nil || errors.As(err, &permanent) {
+		return false
+	}
+	if isNotReady(err) {
+		return true
+	}
+	if apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsInternalError(err) || apierrors.IsServiceUnavailable(err) || apierrors.IsConflict(err) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retry - Run the operation until it succeeds, fails with a permanent error or the deadline of the retry policy
+// has passed.  The last error is returned, prefixed with the description of the operation
+func retry(description string, operation func() error) error {
+	policy := retryPolicy
+	deadline := time.Now().Add(policy.Deadline)
+	delay := policy.InitialDelay
+	for attempt := 1; ; attempt++ {
+		err := operation()
+		if err == nil {
+			return nil
+		}
+		if !IsTransientError(err) {
+			return fmt.Errorf("failed to %s: %w", description, err)
+		}
+		sleep := jitter(delay, policy.Jitter)
+		if time.Now().Add(sleep).After(deadline) {
+			return fmt.Errorf("failed to %s after %d attempts: %w", description, attempt, err)
+		}
+		if isNotReady(err) {
+			log.Printf("   %v", err)
+		} else {
+			log.Printf("WARNING: Failed to %s (attempt %d), retrying in %v: %v", description, attempt, sleep.Round(time.Millisecond), err)
+		}
+		time.Sleep(sleep)
+		delay = time.Duration(float64(delay) * policy.Multiplier)
+		if delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+	}
+}
+
+// jitter - Randomize the delay by +/- the fraction
+func jitter(delay time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return delay
+	}
+	factor := 1 + fraction*(2*rand.Float64()-1) // #nosec G404 jitter does not need a secure random number
+	return time.Duration(float64(delay) * factor)
+}
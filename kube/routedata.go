@@ -0,0 +1,321 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package kube
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Versions of the route data stored in the routes config map
+const (
+	RouteDataVersionLegacy = 1 // One config map key per field, all values are strings
+	RouteDataVersion       = 2 // Single JSON document in the keyRouteData key
+)
+
+const keyRouteData = "routes.json"
+
+// routeDocument - Version 2 of the route data.  Booleans and numbers are typed and lists of subnets are arrays.
+// A number that is 0 was not set
+type routeDocument struct {
+	Version          int      `json:"version"`
+	Generation       int64    `json:"generation"`
+	ConnectUsingLB   bool     `json:"connectUsingLB"`
+	LoadBalancerIP   string   `json:"loadBalancerIP,omitempty"`
+	LoadBalancerIPv6 string   `json:"loadBalancerIPv6,omitempty"`
+	LocalSubnet      []string `json:"localSubnet,omitempty"`
+	LocalSubnetNAT   []string `json:"localSubnetNAT,omitempty"`
+	NonClusterSubnet []string `json:"localNonClusterSubnet,omitempty"`
+	RemoteGateway    string   `json:"remoteGateway,omitempty"`
+	RemoteSubnet     []string `json:"remoteSubnet,omitempty"`
+	RemoteSubnetNAT  []string `json:"remoteSubnetNAT,omitempty"`
+	RouteNamespaces  []string `json:"routeNamespaces,omitempty"`
+	RoutePodSelector string   `json:"routePodSelector,omitempty"`
+	RouteTable       int      `json:"routeTable,omitempty"`
+	RulePriority     int      `json:"rulePriority,omitempty"`
+	TunnelGroup      string   `json:"tunnelGroup,omitempty"`
+	TunnelGroupMode  string   `json:"tunnelGroupMode,omitempty"`
+	TunnelPriority   int      `json:"tunnelPriority,omitempty"`
+	TunnelState      string   `json:"tunnelState,omitempty"`
+	TunnelTable      int      `json:"tunnelTable,omitempty"`
+	TunnelWeight     int      `json:"tunnelWeight,omitempty"`
+	VpnPodDevice     string   `json:"vpnPodDevice,omitempty"`
+	VpnPodIP         string   `json:"vpnPodIP,omitempty"`
+	VpnPodIPv6       string   `json:"vpnPodIPv6,omitempty"`
+	VpnPodName       string   `json:"vpnPodName,omitempty"`
+	WorkerNodeIP     string   `json:"workerNodeIP,omitempty"`
+	WorkerNodeIPv6   string   `json:"workerNodeIPv6,omitempty"`
+	WorkerSubnet     string   `json:"workerSubnet,omitempty"`
+	Zone             string   `json:"zone,omitempty"`
+	ZoneLocalRouting bool     `json:"zoneLocalRouting,omitempty"`
+}
+
+// UnsupportedVersionError - The route data was written by a newer version of the VPN pod
+type UnsupportedVersionError struct {
+	Version int
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("route data version %d is newer than the supported version %d, the route daemon and VPN pod must run the same chart version", e.Version, RouteDataVersion)
+}
+
+// InvalidRouteDataError - The route data does not match its schema
+type InvalidRouteDataError struct {
+	Field string
+	Value string
+}
+
+func (e *InvalidRouteDataError) Error() string {
+	return fmt.Sprintf("invalid route data: %s: %q", e.Field, e.Value)
+}
+
+// MapToRouteData - Extract the routing data from the data of the routes config map.  Data written by older versions
+// of the VPN pod is converted.  An error is returned if the data was written by a newer version or is not valid
+func MapToRouteData(mapData map[string]string) (RouteData, error) {
+	value, ok := mapData[keyRouteData]
+	if !ok {
+		return legacyMapToRouteData(mapData), nil
+	}
+	header := struct {
+		Version int `json:"version"`
+	}{}
+	if err := json.Unmarshal([]byte(value), &header); err != nil {
+		return RouteData{}, fmt.Errorf("invalid route data: %v", err)
+	}
+	if header.Version > RouteDataVersion {
+		return RouteData{}, &UnsupportedVersionError{Version: header.Version}
+	}
+	if header.Version < RouteDataVersion {
+		return RouteData{}, &InvalidRouteDataError{Field: "version", Value: strconv.Itoa(header.Version)}
+	}
+	document := routeDocument{}
+	if err := json.Unmarshal([]byte(value), &document); err != nil {
+		return RouteData{}, fmt.Errorf("invalid route data: %v", err)
+	}
+	if err := document.validate(); err != nil {
+		return RouteData{}, err
+	}
+	return document.routeData(), nil
+}
+
+// RouteDataToMap - Build the data of the routes config map from the routing data.  An error is returned if the
+// routing data is not valid
+func RouteDataToMap(routeData RouteData) (map[string]string, error) {
+	document, err := newRouteDocument(routeData)
+	if err != nil {
+		return nil, err
+	}
+	if err := document.validate(); err != nil {
+		return nil, err
+	}
+	// "<pending>" is kept readable
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	return map[string]string{keyRouteData: strings.TrimSpace(buffer.String())}, nil
+}
+
+// RouteDataGeneration - Get the generation of the data of the routes config map, for any version of the route data
+func RouteDataGeneration(mapData map[string]string) string {
+	value, ok := mapData[keyRouteData]
+	if !ok {
+		return mapData[keyGeneration]
+	}
+	header := struct {
+		Generation int64 `json:"generation"`
+	}{}
+	if err := json.Unmarshal([]byte(value), &header); err != nil || header.Generation == 0 {
+		return ""
+	}
+	return strconv.FormatInt(header.Generation, 10)
+}
+
+// Convert the routing data to the version 2 document
+func newRouteDocument(routeData RouteData) (routeDocument, error) {
+	document := routeDocument{
+		Version:          RouteDataVersion,
+		ConnectUsingLB:   routeData.ConnectUsingLB == "true",
+		LoadBalancerIP:   routeData.LoadBalancerIP,
+		LoadBalancerIPv6: routeData.LoadBalancerIPv6,
+		LocalSubnet:      splitList(routeData.LocalSubnet),
+		LocalSubnetNAT:   splitList(routeData.LocalSubnetNAT),
+		NonClusterSubnet: splitList(routeData.NonClusterSubnet),
+		RemoteGateway:    routeData.RemoteGateway,
+		RemoteSubnet:     splitList(routeData.RemoteSubnet),
+		RemoteSubnetNAT:  splitList(routeData.RemoteSubnetNAT),
+		RouteNamespaces:  splitList(routeData.RouteNamespaces),
+		RoutePodSelector: routeData.RoutePodSelector,
+		TunnelGroup:      routeData.TunnelGroup,
+		TunnelGroupMode:  routeData.TunnelGroupMode,
+		TunnelState:      routeData.TunnelState,
+		VpnPodDevice:     routeData.VpnPodDevice,
+		VpnPodIP:         routeData.VpnPodIP,
+		VpnPodIPv6:       routeData.VpnPodIPv6,
+		VpnPodName:       routeData.VpnPodName,
+		WorkerNodeIP:     routeData.WorkerNodeIP,
+		WorkerNodeIPv6:   routeData.WorkerNodeIPv6,
+		WorkerSubnet:     routeData.WorkerSubnet,
+		Zone:             routeData.Zone,
+		ZoneLocalRouting: routeData.ZoneLocalRouting == "true",
+	}
+	numbers := []struct {
+		field  string
+		value  string
+		target *int
+	}{
+		{keyRouteTable, routeData.RouteTable, &document.RouteTable},
+		{keyRulePriority, routeData.RulePriority, &document.RulePriority},
+		{keyTunnelPriority, routeData.TunnelPriority, &document.TunnelPriority},
+		{keyTunnelTable, routeData.TunnelTable, &document.TunnelTable},
+		{keyTunnelWeight, routeData.TunnelWeight, &document.TunnelWeight},
+	}
+	for _, number := range numbers {
+		if number.value == "" {
+			continue
+		}
+		value, err := strconv.Atoi(number.value)
+		if err != nil {
+			return routeDocument{}, &InvalidRouteDataError{Field: number.field, Value: number.value}
+		}
+		*number.target = value
+	}
+	if routeData.Generation != "" {
+		generation, err := strconv.ParseInt(routeData.Generation, 10, 64)
+		if err != nil {
+			return routeDocument{}, &InvalidRouteDataError{Field: keyGeneration, Value: routeData.Generation}
+		}
+		document.Generation = generation
+	}
+	return document, nil
+}
+
+// Convert the version 2 document to the routing data
+func (document routeDocument) routeData() RouteData {
+	return RouteData{
+		ConnectUsingLB:   strconv.FormatBool(document.ConnectUsingLB),
+		Generation:       formatNumber(document.Generation),
+		LoadBalancerIP:   document.LoadBalancerIP,
+		LoadBalancerIPv6: document.LoadBalancerIPv6,
+		LocalSubnet:      strings.Join(document.LocalSubnet, ","),
+		LocalSubnetNAT:   strings.Join(document.LocalSubnetNAT, ","),
+		NonClusterSubnet: strings.Join(document.NonClusterSubnet, ","),
+		RemoteGateway:    document.RemoteGateway,
+		RemoteSubnet:     strings.Join(document.RemoteSubnet, ","),
+		RemoteSubnetNAT:  strings.Join(document.RemoteSubnetNAT, ","),
+		RouteNamespaces:  strings.Join(document.RouteNamespaces, ","),
+		RoutePodSelector: document.RoutePodSelector,
+		RouteTable:       formatNumber(int64(document.RouteTable)),
+		RulePriority:     formatNumber(int64(document.RulePriority)),
+		TunnelGroup:      document.TunnelGroup,
+		TunnelGroupMode:  document.TunnelGroupMode,
+		TunnelPriority:   formatNumber(int64(document.TunnelPriority)),
+		TunnelState:      document.TunnelState,
+		TunnelTable:      formatNumber(int64(document.TunnelTable)),
+		TunnelWeight:     formatNumber(int64(document.TunnelWeight)),
+		VpnPodDevice:     document.VpnPodDevice,
+		VpnPodIP:         document.VpnPodIP,
+		VpnPodIPv6:       document.VpnPodIPv6,
+		VpnPodName:       document.VpnPodName,
+		WorkerNodeIP:     document.WorkerNodeIP,
+		WorkerNodeIPv6:   document.WorkerNodeIPv6,
+		WorkerSubnet:     document.WorkerSubnet,
+		Zone:             document.Zone,
+		ZoneLocalRouting: strconv.FormatBool(document.ZoneLocalRouting),
+	}
+}
+
+// validate - Verify the fields of the document against the schema of the route data.  The remote gateway and the
+// local and remote subnets come from ipsec.conf, which is not validated when 'validate' is off, so they are not
+// checked here
+func (document routeDocument) validate() error {
+	if document.Version != RouteDataVersion {
+		return &InvalidRouteDataError{Field: "version", Value: strconv.Itoa(document.Version)}
+	}
+	if document.Generation < 0 {
+		return &InvalidRouteDataError{Field: keyGeneration, Value: formatNumber(document.Generation)}
+	}
+	addresses := map[string]string{
+		keyLoadBalancerIPv6: document.LoadBalancerIPv6,
+		keyVpnPodIP:         document.VpnPodIP,
+		keyVpnPodIPv6:       document.VpnPodIPv6,
+		keyWorkerNodeIP:     document.WorkerNodeIP,
+		keyWorkerNodeIPv6:   document.WorkerNodeIPv6,
+	}
+	// The load balancer IP is "<pending>" until it is assigned
+	if document.LoadBalancerIP != "<pending>" {
+		addresses[keyLoadBalancerIP] = document.LoadBalancerIP
+	}
+	for field, address := range addresses {
+		if address != "" && net.ParseIP(address) == nil {
+			return &InvalidRouteDataError{Field: field, Value: address}
+		}
+	}
+	subnets := map[string][]string{
+		keyNonClusterSubnet: document.NonClusterSubnet,
+		keyWorkerSubnet:     splitList(document.WorkerSubnet),
+	}
+	for field, list := range subnets {
+		for _, subnet := range list {
+			if _, _, err := net.ParseCIDR(subnet); err != nil {
+				return &InvalidRouteDataError{Field: field, Value: subnet}
+			}
+		}
+	}
+	for field, rules := range map[string][]string{keyLocalSubnetNAT: document.LocalSubnetNAT, keyRemoteSubnetNAT: document.RemoteSubnetNAT} {
+		for _, rule := range rules {
+			if !strings.Contains(rule, "=") {
+				return &InvalidRouteDataError{Field: field, Value: rule}
+			}
+		}
+	}
+	numbers := map[string]int{
+		keyRouteTable:     document.RouteTable,
+		keyRulePriority:   document.RulePriority,
+		keyTunnelPriority: document.TunnelPriority,
+		keyTunnelTable:    document.TunnelTable,
+		keyTunnelWeight:   document.TunnelWeight,
+	}
+	for field, number := range numbers {
+		if number < 0 {
+			return &InvalidRouteDataError{Field: field, Value: strconv.Itoa(number)}
+		}
+	}
+	if document.TunnelGroupMode != "" && document.TunnelGroupMode != TunnelGroupFailover && document.TunnelGroupMode != TunnelGroupECMP {
+		return &InvalidRouteDataError{Field: keyTunnelGroupMode, Value: document.TunnelGroupMode}
+	}
+	if document.TunnelState != "" && document.TunnelState != TunnelStateUp && document.TunnelState != TunnelStateDown {
+		return &InvalidRouteDataError{Field: keyTunnelState, Value: document.TunnelState}
+	}
+	return nil
+}
+
+// splitList - Split a "," separated list, an empty string is an empty list
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// formatNumber - Format a number of the document, 0 was not set
+func formatNumber(value int64) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatInt(value, 10)
+}
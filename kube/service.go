@@ -2,7 +2,7 @@
  * IBM Confidential
  * OCO Source Materials
  * IBM Cloud Kubernetes Service, 5737-D43
- * (C) Copyright IBM Corp. 2017, 2020 All Rights Reserved.
+ * (C) Copyright IBM Corp. 2017, 2026 All Rights Reserved.
  * The source code for this program is not published or otherwise divested of
  * its trade secrets, irrespective of what has been deposited with
  * the U.S. Copyright Office.
@@ -12,70 +12,183 @@ package kube
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
-var serviceRetryCount = 5
+const resolveTimeout = 10 * time.Second // Timeout of the DNS lookup of a load balancer hostname
 
 // CalculateRouterTable - Determine which router table to use based on load balancer IP
 func CalculateRouterTable(loadBalancerIP string) string {
-	// If external IP was not assigned to load balancer, default to table 200
-	if loadBalancerIP == "<pending>" {
+	// If external IP was not assigned to load balancer (or is not known), default to table 200
+	ip := net.ParseIP(loadBalancerIP)
+	if ip == nil {
 		return "200"
 	}
 
 	// Since we are only looking at the last byte of the public IP subnet, this should result in
-	// values of 202-205 and 210-213 assuming that /29 is used for public range
-	lastDigit := strings.Split(loadBalancerIP, ".")[3]
-	num, err := strconv.Atoi(lastDigit)
-	if err != nil {
-		log.Printf("strconv.Atoi failed: %v", err)
-		num = 0
-	}
+	// values of 202-205 and 210-213 assuming that /29 is used for public range.  IPv6 addresses use the last byte too
+	lastByte := int(ip[len(ip)-1])
 
 	// Return 200 + [1 ... 15]
-	return strconv.Itoa(200 + (num & 0xF))
+	return strconv.Itoa(200 + (lastByte & 0xF))
 }
 
-// GetLoadBalancerIP - Get the Load Balancer IP for a specific service
-func GetLoadBalancerIP(client *kubernetes.Clientset, namespace, serviceName, requestedLoadBalancerIP, ipsecAuto string, connectUsingVip bool) string {
-	for i := 1; i <= serviceRetryCount; i++ {
+// GetLoadBalancerIP - Get the Load Balancer IP for a specific service.  Waits for the IP to be assigned, as allowed by
+// the retry policy.  "<pending>" is returned if the IP is not assigned and the VPN does not require it
+func GetLoadBalancerIP(client *kubernetes.Clientset, namespace, serviceName, requestedLoadBalancerIP, ipsecAuto string, connectUsingVip bool) (string, error) {
+	loadBalancerIP := ""
+	err := retry(fmt.Sprintf("get service %s/%s", namespace, serviceName), func() error {
 		service, err := client.CoreV1().Services(namespace).Get(context.TODO(), serviceName, metav1.GetOptions{})
 		if err != nil {
-			log.Fatalf("ERROR: Failed to get service: %v", err)
+			return err
 		}
 
-		// If external IP was assigned to the service, return with that IP address
-		if len(service.Status.LoadBalancer.Ingress) > 0 {
-			return service.Status.LoadBalancer.Ingress[0].IP
+		// If external IP was assigned to the service, return with that IP address.  A load balancer that publishes a
+		// hostname is not ready until the hostname resolves
+		ipv4, ipv6 := GetLoadBalancerIngressIPs(service, "", "")
+		if ipv4 != "" || ipv6 != "" {
+			loadBalancerIP = ipv4
+			if loadBalancerIP == "" {
+				loadBalancerIP = ipv6
+			}
+			return nil
 		}
 
 		// Don't wait for an external IP to be assigned to Kube service if (1) outbound connection, (2) LB IP was not specified, AND (3) not using LB IP for connect
 		if ipsecAuto == "start" && requestedLoadBalancerIP == "" && !connectUsingVip {
-			break
+			return nil
 		}
 
-		// Service does not have a LB IP yet. Sleep for a second and try again
-		log.Print("   load balancer ip: <pending>")
-		time.Sleep(time.Second)
+		// Service does not have a LB IP yet. Try again
+		return notReady("load balancer ip: <pending>")
+	})
+	if loadBalancerIP != "" {
+		return loadBalancerIP, nil
+	}
+	if err != nil && !isNotReady(err) {
+		return "", err
 	}
 
 	// If the user requested a specific Load Balancer IP address, fail if it was not assigned to the service
 	if requestedLoadBalancerIP != "" {
-		log.Fatalf("ERROR: Load balancer service was not assigned the requested external IP: %s", requestedLoadBalancerIP)
+		return "", &PermanentError{Err: fmt.Errorf("load balancer service was not assigned the requested external IP: %s", requestedLoadBalancerIP)}
 	}
 
 	// If setting up a listening VPN service, fail if we didn't get an public IP address
 	if ipsecAuto == "add" {
-		log.Fatalf("ERROR: Load balancer VPN service was not assigned a public IP")
+		return "", &PermanentError{Err: fmt.Errorf("load balancer VPN service was not assigned a public IP")}
 	}
 
 	// Return string indicating external IP was not assigned to the service
-	return "<pending>"
+	return "<pending>", nil
+}
+
+// GetLoadBalancerIPv6 - Get the IPv6 address assigned to a dual stack Load Balancer service.  An empty string is
+// returned if the service does not have an IPv6 address
+func GetLoadBalancerIPv6(client *kubernetes.Clientset, namespace, serviceName string) (string, error) {
+	loadBalancerIPv6 := ""
+	err := retry(fmt.Sprintf("get service %s/%s", namespace, serviceName), func() error {
+		service, err := client.CoreV1().Services(namespace).Get(context.TODO(), serviceName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		_, loadBalancerIPv6 = GetLoadBalancerIngressIPs(service, "", "")
+		return nil
+	})
+	return loadBalancerIPv6, err
+}
+
+// GetLoadBalancerIngressIPs - Get the IPv4 and IPv6 addresses assigned to a Load Balancer service.  Load balancers
+// that publish a hostname instead of an IP are resolved.  If the hostname resolves to several addresses, the current
+// address is kept while it is still one of them, otherwise the first address in sorted order is used.  Empty
+// strings are returned for the addresses that have not been assigned
+func GetLoadBalancerIngressIPs(service *corev1.Service, currentIP, currentIPv6 string) (string, string) {
+	ipv4s, ipv6s := []string{}, []string{}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		addresses := []string{ingress.IP}
+		if ingress.IP == "" && ingress.Hostname != "" {
+			addresses = resolveHostname(ingress.Hostname)
+		}
+		for _, address := range addresses {
+			if net.ParseIP(address) == nil {
+				continue
+			}
+			if strings.Contains(address, ":") {
+				ipv6s = append(ipv6s, address)
+			} else {
+				ipv4s = append(ipv4s, address)
+			}
+		}
+	}
+	return selectAddress(ipv4s, currentIP), selectAddress(ipv6s, currentIPv6)
+}
+
+// resolveHostname - Look up the addresses of the load balancer hostname
+func resolveHostname(hostname string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupHost(ctx, hostname)
+	if err != nil {
+		log.Printf("WARNING: Unable to resolve the load balancer hostname %s: %v", hostname, err)
+		return nil
+	}
+	return addresses
+}
+
+// selectAddress - Keep the current address if it is still in the list, otherwise use the first address in sorted order
+func selectAddress(addresses []string, current string) string {
+	if len(addresses) == 0 {
+		return ""
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		if address == current {
+			return current
+		}
+	}
+	if len(addresses) > 1 {
+		log.Printf("Load balancer has multiple addresses %v, using %s", addresses, addresses[0])
+	}
+	return addresses[0]
+}
+
+// WatchService - watch for updates to the service and calls the provided routines
+func WatchService(client *kubernetes.Clientset, namespace, serviceName string,
+	addFunc func(obj interface{}),
+	deleteFunc func(obj interface{}),
+	updateFunc func(oldObj, newObj interface{})) {
+	log.Printf("Create watchList for service changes: %s/%s", namespace, serviceName)
+	watchList := cache.NewListWatchFromClient(
+		client.CoreV1().RESTClient(),
+		corev1.ResourceServices.String(),
+		namespace,
+		fields.OneTermEqualSelector("metadata.name", serviceName))
+
+	// Handler routine for add/delete/update events
+	_, controller := cache.NewInformerWithOptions(cache.InformerOptions{
+		ListerWatcher: watchList,
+		ObjectType:    &corev1.Service{},
+		ResyncPeriod:  0,
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc:    addFunc,
+			DeleteFunc: deleteFunc,
+			UpdateFunc: updateFunc,
+		},
+	})
+	if controller == nil { // Should only be nil for unit tests
+		return
+	}
+	go controller.Run(make(chan struct{}))
 }
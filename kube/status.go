@@ -0,0 +1,110 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Result of applying the routes config map on a worker node
+const (
+	RouteResultApplied = "applied"
+	RouteResultFailed  = "failed"
+)
+
+// Sync state of a worker node, compared to the current generation of the routes config map
+const (
+	RouteStateInSync  = "in-sync" // Latest generation was applied
+	RouteStateStale   = "stale"   // An older generation was applied
+	RouteStateFailing = "failing" // Applying the routes failed
+	RouteStateMissing = "missing" // Route daemon on the node has not reported anything
+)
+
+// RouteStatus - Acknowledgement published by the route daemon on each worker node
+type RouteStatus struct {
+	Generation string `json:"generation"`
+	Result     string `json:"result"`
+	Time       string `json:"time"`
+	Error      string `json:"error,omitempty"`
+	Subnet     string `json:"subnet,omitempty"` // Subnet of the worker node, determined in the network namespace of the node
+}
+
+// StatusConfigMapName - Name of the config map holding the acknowledgements of the release
+func StatusConfigMapName(releaseName string) string {
+	return releaseName + "-strongswan-status"
+}
+
+// State - Determine the sync state of the node for the current generation of the routes config map
+func (status RouteStatus) State(generation string) string {
+	switch {
+	case status.Result == RouteResultFailed:
+		return RouteStateFailing
+	case status.Generation != generation:
+		return RouteStateStale
+	}
+	return RouteStateInSync
+}
+
+// PublishRouteStatus - Store the acknowledgement of the node in the status config map.  A merge patch is used so
+// route daemons on different nodes can update the config map at the same time
+func PublishRouteStatus(client *kubernetes.Clientset, namespace, configMapName, nodeName string, status RouteStatus) error {
+	data, _ := json.Marshal(status) // #nosec G104 marshal of a struct of strings can not fail
+	return patchStatusKey(client, namespace, configMapName, nodeName, string(data))
+}
+
+// RemoveRouteStatus - Remove the acknowledgement of the node from the status config map
+func RemoveRouteStatus(client *kubernetes.Clientset, namespace, configMapName, nodeName string) error {
+	return patchStatusKey(client, namespace, configMapName, nodeName, nil)
+}
+
+// GetRouteStatus - Retrieve the acknowledgements of all of the nodes
+func GetRouteStatus(client *kubernetes.Clientset, namespace, configMapName string) (map[string]RouteStatus, error) {
+	cm, err := getConfigMap(client, namespace, configMapName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve %s/%s: %v", namespace, configMapName, err)
+	}
+	statusMap := map[string]RouteStatus{}
+	for nodeName, value := range cm.Data {
+		status := RouteStatus{}
+		if err := json.Unmarshal([]byte(value), &status); err != nil {
+			log.Printf("WARNING: Ignoring invalid status for node %s: %s", nodeName, value)
+			continue
+		}
+		statusMap[nodeName] = status
+	}
+	return statusMap, nil
+}
+
+// GetRouteGeneration - Retrieve the current generation of the routes config map
+func GetRouteGeneration(client *kubernetes.Clientset, namespace, configMapName string) (string, error) {
+	cm, err := getConfigMap(client, namespace, configMapName)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve %s/%s: %v", namespace, configMapName, err)
+	}
+	return RouteDataGeneration(cm.Data), nil
+}
+
+// patchStatusKey - Set (or remove if value is nil) a single key in the status config map
+func patchStatusKey(client *kubernetes.Clientset, namespace, configMapName, key string, value interface{}) error {
+	patch, _ := json.Marshal(map[string]interface{}{"data": map[string]interface{}{key: value}}) // #nosec G104 marshal of a map of strings can not fail
+	_, err := client.CoreV1().ConfigMaps(namespace).Patch(context.TODO(), configMapName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update %s/%s: %v", namespace, configMapName, err)
+	}
+	return nil
+}
@@ -2,7 +2,7 @@
  * IBM Confidential
  * OCO Source Materials
  * IBM Cloud Kubernetes Service, 5737-D43
- * (C) Copyright IBM Corp. 2018, 2022 All Rights Reserved.
+ * (C) Copyright IBM Corp. 2018, 2026 All Rights Reserved.
  * The source code for this program is not published or otherwise divested of
  * its trade secrets, irrespective of what has been deposited with
  * the U.S. Copyright Office.
@@ -33,7 +33,12 @@ func readConfigFile(monitoringConfigFile string) {
 	if err != nil {
 		log.Fatalf("monitoring | ERROR: Un-marshalling config file: %v", err)
 	}
-	log.Printf("monitoring | Config: %+v", monitorCfg)
+	// The legacy Slack webhook URL contains its credentials
+	logCfg := monitorCfg
+	if logCfg.SlackWebhook != "" {
+		logCfg.SlackWebhook = "<set>"
+	}
+	log.Printf("monitoring | Config: %+v", logCfg)
 }
 
 // Validate - used to do basic validation of monitoring parameter values and set them to default values if there is an issue.
@@ -46,8 +51,8 @@ func validate() {
 		verifyEndpoint(strings.Split(monitorCfg.HTTPEndpoints, ","))
 	}
 
-	if monitorCfg.PrivateIPs == "" && monitorCfg.HTTPEndpoints == "" {
-		log.Fatalf("monitoring | ERROR: Either monitoring.privateIPs or monitoring.httpEndpoints must be configured")
+	if monitorCfg.PrivateIPs == "" && monitorCfg.HTTPEndpoints == "" && len(monitorCfg.Probes) == 0 {
+		log.Fatalf("monitoring | ERROR: One of monitoring.privateIPs, monitoring.httpEndpoints or monitoring.probes must be configured")
 	}
 
 	if monitorCfg.Timeout <= 0 {
@@ -58,12 +63,15 @@ func validate() {
 		log.Fatalf("monitoring | ERROR: monitoring.delay is set to an incorrect value: %d", monitorCfg.Delay)
 	}
 
-	if monitorCfg.SlackWebhook != "" {
-		rc := sendSlackMessage("Initializing monitor logic")
-		if rc != 200 {
-			log.Fatalf("monitoring | ERROR: Unable to post message to the Slack channel")
-		}
-	} else {
-		log.Print("monitoring | Slack notifications have not been configured")
+	// The probes default to monitoring.timeout and monitoring.delay
+	verifyProbes()
+
+	if err := initNotifiers(); err != nil {
+		log.Fatalf("monitoring | ERROR: %v", err)
+	}
+	if len(notifiers) == 0 {
+		log.Print("monitoring | Notifications have not been configured")
+	} else if !notify(EventInfo, "Initializing monitor logic") {
+		log.Fatalf("monitoring | ERROR: Unable to send a notification to all of the notification channels")
 	}
 }
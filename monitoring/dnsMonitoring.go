@@ -0,0 +1,63 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// dnsTest - resolve the host name with the DNS server and check that the answer contains the expected IPs
+func dnsTest(server, query string, expect []net.IP, timeout time.Duration) error {
+	// Send the query to the DNS server instead of the resolvers of the pod
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// An absolute name is not expanded with the search domains of the pod, which the DNS server does not know
+	fqdn := query
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	answer, err := resolver.LookupIPAddr(ctx, fqdn)
+	if err != nil {
+		// The error names the DNS server from resolv.conf, which is not the one that was queried
+		if dnsErr, ok := err.(*net.DNSError); ok {
+			return fmt.Errorf("lookup %s: %s", query, dnsErr.Err)
+		}
+		return err
+	}
+	addresses := []string{}
+	for _, address := range answer {
+		addresses = append(addresses, address.IP.String())
+	}
+	for _, ip := range expect {
+		found := false
+		for _, address := range answer {
+			if address.IP.Equal(ip) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("answer [%s] does not contain %s", strings.Join(addresses, ","), ip)
+		}
+	}
+	return nil
+}
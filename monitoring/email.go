@@ -0,0 +1,138 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package monitoring
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	emailDefaultPort    = 587
+	emailDefaultSubject = "strongSwan VPN {{.Kind}}"
+)
+
+// emailNotifier - Send the notifications by SMTP.  STARTTLS is used when the server supports it and is required
+// for authentication
+type emailNotifier struct {
+	host     string
+	port     int
+	from     string
+	to       []string
+	username string
+	password string
+	subject  string
+	template string
+}
+
+func newEmailNotifier(cfg notificationConfig, tmpl string) (Notifier, error) {
+	n := &emailNotifier{host: cfg.Host, port: cfg.Port, from: cfg.From, username: cfg.Username, subject: cfg.Subject, template: tmpl}
+	for _, to := range strings.Split(cfg.To, ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			n.to = append(n.to, to)
+		}
+	}
+	if n.host == "" || n.from == "" || len(n.to) == 0 {
+		return nil, fmt.Errorf("host, from and to must be set")
+	}
+	if n.port == 0 {
+		n.port = emailDefaultPort
+	}
+	if n.subject == "" {
+		n.subject = emailDefaultSubject
+	}
+	if _, err := template.New("subject").Parse(n.subject); err != nil {
+		return nil, fmt.Errorf("invalid subject: %v", err)
+	}
+	if n.username != "" {
+		password, err := readSecret(cfg.PasswordSecret, "smtpPassword")
+		if err != nil {
+			return nil, err
+		}
+		n.password = password
+	}
+	return n, nil
+}
+
+func (n *emailNotifier) Name() string {
+	return notifierEmail
+}
+
+func (n *emailNotifier) Send(event Event) error {
+	text, err := event.render(n.template)
+	if err != nil {
+		return err
+	}
+	subject, err := event.render(n.subject)
+	if err != nil {
+		return err
+	}
+	message := "From: " + n.from + "\r\n" +
+		"To: " + strings.Join(n.to, ", ") + "\r\n" +
+		"Subject: " + strings.ReplaceAll(subject, "\n", " ") + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + strings.ReplaceAll(text, "\n", "\r\n") + "\r\n"
+	return n.sendMail([]byte(message))
+}
+
+// sendMail - Same as smtp.SendMail, with a timeout so a server that does not respond does not block the monitoring
+func (n *emailNotifier) sendMail(message []byte) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(n.host, strconv.Itoa(n.port)), httpTimeout)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(httpTimeout)); err != nil {
+		conn.Close() // #nosec G104 the connection is not used
+		return err
+	}
+	client, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		conn.Close() // #nosec G104 the connection is not used
+		return err
+	}
+	defer client.Close() // #nosec G307 safe to call close() here
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if n.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.username, n.password, n.host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(n.from); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
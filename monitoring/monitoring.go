@@ -2,7 +2,7 @@
  * IBM Confidential
  * OCO Source Materials
  * IBM Cloud Kubernetes Service, 5737-D43
- * (C) Copyright IBM Corp. 2018, 2020 All Rights Reserved.
+ * (C) Copyright IBM Corp. 2018, 2026 All Rights Reserved.
  * The source code for this program is not published or otherwise divested of
  * its trade secrets, irrespective of what has been deposited with
  * the U.S. Copyright Office.
@@ -20,15 +20,18 @@ import (
 
 // monitorYamlConfig - struct that contains the monitoring configuration information
 type monitorYamlConfig struct {
-	ClusterName   string `yaml:"clusterName"`
-	Delay         int    `yaml:"delay"`
-	HTTPEndpoints string `yaml:"httpEndpoints"`
-	PrivateIPs    string `yaml:"privateIPs"`
-	SlackWebhook  string `yaml:"slackWebhook"`
-	SlackChannel  string `yaml:"slackChannel"`
-	SlackUsername string `yaml:"slackUsername"`
-	SlackIcon     string `yaml:"slackIcon"`
-	Timeout       int    `yaml:"timeout"`
+	ClusterName          string               `yaml:"clusterName"`
+	Delay                int                  `yaml:"delay"`
+	HTTPEndpoints        string               `yaml:"httpEndpoints"`
+	NotificationTemplate string               `yaml:"notificationTemplate"`
+	Notifications        []notificationConfig `yaml:"notifications"`
+	PrivateIPs           string               `yaml:"privateIPs"`
+	Probes               []probeConfig        `yaml:"probes"`
+	SlackWebhook         string               `yaml:"slackWebhook"`
+	SlackChannel         string               `yaml:"slackChannel"`
+	SlackUsername        string               `yaml:"slackUsername"`
+	SlackIcon            string               `yaml:"slackIcon"`
+	Timeout              int                  `yaml:"timeout"`
 }
 
 type monitorTestResults struct {
@@ -41,9 +44,15 @@ const (
 )
 
 var (
+	healthHandler         func(healthy bool)
 	monitorActive         bool
+	monitorHealthy        bool
+	monitorHealthReported bool
 	monitorCfg            monitorYamlConfig
+	monitorClusterID      string
+	monitorPodName        string
 	monitorResult         monitorTestResults
+	monitorTested         bool
 	podLocationInfo       string
 	stopMonitor           bool
 	stopMonitorThreadChan = make(chan string, 1)
@@ -54,6 +63,7 @@ var (
 // Start - request to start the monitoring testing
 func Start() {
 	timeToRunMonitoring = time.Now().Unix() + 2 // Add 2 seconds to allow VPN tunnel to be ready
+	startProbes(timeToRunMonitoring)
 	stopMonitor = false
 	monitorActive = true
 }
@@ -64,9 +74,17 @@ func Stop() {
 	stopMonitor = true
 }
 
+// SetHealthHandler - register a function that is called each time the result of the monitoring tests changes
+// between healthy and failing
+func SetHealthHandler(handler func(healthy bool)) {
+	healthHandler = handler
+}
+
 // Init - used to setup the monitoring struct and validate the struct, and start the monitoring goroutine
 func Init(podName, clusterID string) {
 	readConfigFile(monConfigFile)
+	monitorPodName = podName
+	monitorClusterID = clusterID
 	podLocationInfo = podLocation(podName, monitorCfg.ClusterName, clusterID)
 	validate()
 	go monitorThread()
@@ -75,27 +93,28 @@ func Init(podName, clusterID string) {
 // Cancel - used to stop the monitoring. Called when the VPN pod is ending
 func Cancel() {
 	stopMonitorThreadChan <- "Stop"
-	message := "VPN pod is exiting.  VPN is going down"
-	log.Printf("monitoring | %s", message)
-	sendSlackMessage(message)
+	// The pod is replaced on every rolling update, so this does not trigger an incident.  The VPN pod that replaces
+	// it reports the result of its own monitoring tests
+	notify(EventInfo, "VPN pod is exiting.  VPN is going down")
 }
 
 // monitorThread - code to run the monitoring
 func monitorThread() {
 	for {
 		if monitorActive {
-			if time.Now().Unix() >= timeToRunMonitoring {
-				execute()
-				timeToRunMonitoring = time.Now().Unix() + int64(monitorCfg.Delay)
-			}
+			execute()
 			if stopMonitor && (time.Now().Unix() >= timeToLive) {
 				monitorResult.curl = ""
 				monitorResult.ping = ""
+				resetProbes()
+				monitorTested = false
 				monitorActive = false
 				stopMonitor = false
-				message := "VPN is down"
-				log.Printf("monitoring | %s", message)
-				sendSlackMessage(message)
+				if reportHealth(false) {
+					notify(EventDown, "VPN is down")
+				} else {
+					notify(EventInfo, "VPN is down")
+				}
 			}
 		}
 		select {
@@ -107,34 +126,65 @@ func monitorThread() {
 	}
 }
 
-// execute - executes the monitoring tests
+// execute - executes the monitoring tests that are due.  Each of the probes is run on its own interval
 func execute() {
-	log.Print("monitoring | Test network connectivity over the VPN")
 	message := ""
-	if monitorCfg.PrivateIPs != "" {
-		ipOutput := runTest("ping", monitorCfg.PrivateIPs)
-		if monitorResult.ping != ipOutput {
-			monitorResult.ping = ipOutput
-			message += ipOutput
+	if time.Now().Unix() >= timeToRunMonitoring {
+		if monitorCfg.PrivateIPs != "" || monitorCfg.HTTPEndpoints != "" {
+			log.Print("monitoring | Test network connectivity over the VPN")
 		}
-	}
-	if monitorCfg.HTTPEndpoints != "" {
-		curlOutput := runTest("curl", monitorCfg.HTTPEndpoints)
-		if monitorResult.curl != curlOutput {
-			monitorResult.curl = curlOutput
-			message += curlOutput
+		if monitorCfg.PrivateIPs != "" {
+			ipOutput := runTest("ping", monitorCfg.PrivateIPs)
+			if monitorResult.ping != ipOutput {
+				monitorResult.ping = ipOutput
+				message += ipOutput
+			}
+		}
+		if monitorCfg.HTTPEndpoints != "" {
+			curlOutput := runTest("curl", monitorCfg.HTTPEndpoints)
+			if monitorResult.curl != curlOutput {
+				monitorResult.curl = curlOutput
+				message += curlOutput
+			}
 		}
+		timeToRunMonitoring = time.Now().Unix() + int64(monitorCfg.Delay)
 	}
+	message += runProbes()
 
-	// If ping or curl output has changed, then message will be set
+	// The VPN is only reported up or down when the result of all of the tests changes between healthy and failing.
+	// If ping, curl or probe output has changed otherwise (ex: one test recovered while another is still failing),
+	// then message will be set and is only reported for information
 	if message != "" {
-		if strings.Contains(message, "Success") {
-			message += "VPN is up"
+		monitorTested = true
+	}
+	if !monitorTested {
+		return // None of the tests have run since the monitoring was started
+	}
+	healthy := !strings.Contains(monitorResult.ping+monitorResult.curl, "Failed") && probesHealthy()
+	switch {
+	case reportHealth(healthy):
+		if healthy {
+			notify(EventUp, message+"VPN is up")
 		} else {
-			message += "Monitoring tests failed. VPN may be down"
+			notify(EventDown, message+"Monitoring tests failed. VPN may be down")
 		}
-		sendSlackMessage(message)
+	case message != "":
+		notify(EventInfo, message+"Monitoring test results changed")
+	}
+}
+
+// reportHealth - record the result of the monitoring tests and call the health handler if it has changed between
+// healthy and failing.  The first result is always a change.  Returns whether the result changed
+func reportHealth(healthy bool) bool {
+	if monitorHealthReported && healthy == monitorHealthy {
+		return false
+	}
+	monitorHealthReported = true
+	monitorHealthy = healthy
+	if healthHandler != nil {
+		healthHandler(healthy)
 	}
+	return true
 }
 
 // runTest - runs the monitoring tests and returns the results
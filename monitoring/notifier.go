@@ -0,0 +1,236 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package monitoring
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Kinds of notification events
+const (
+	EventInfo = "info" // Change of the VPN configuration or of the VPN pod
+	EventUp   = "up"   // Monitoring tests are passing
+	EventDown = "down" // VPN is down or the monitoring tests are failing
+)
+
+// Supported notification channels
+const (
+	notifierSlack     = "slack"
+	notifierWebhook   = "webhook"
+	notifierPagerDuty = "pagerduty"
+	notifierTeams     = "teams"
+	notifierEmail     = "email"
+)
+
+const (
+	notificationSecretDir = "/etc/ipsec.notifications/" // Keys of the notification secret, mounted by the deployment
+	defaultTemplate       = "{{.Location}}{{.Message}}"
+	httpTimeout           = 10 * time.Second
+)
+
+// Event - Notification sent to each of the channels
+type Event struct {
+	Kind     string // EventInfo, EventUp or EventDown
+	Location string // VPN pod and cluster that sent the notification, ends with a newline
+	Message  string
+	Time     string // RFC3339 time of the event
+}
+
+// Notifier - Channel that the notifications of the VPN pod are sent to
+type Notifier interface {
+	// Name of the channel, used in the logs
+	Name() string
+	// Send - Deliver the event to the channel
+	Send(event Event) error
+}
+
+// notificationConfig - Settings of a notification channel in monitoring.conf.  The *Secret fields are the names of
+// keys in the notification secret, so webhook URLs, tokens and passwords are not stored in the config map
+type notificationConfig struct {
+	Type           string `yaml:"type"`
+	Template       string `yaml:"template"`       // Go template of the message, default: notificationTemplate
+	URLSecret      string `yaml:"urlSecret"`      // slack, teams, webhook: key holding the URL
+	TokenSecret    string `yaml:"tokenSecret"`    // webhook: key holding the HMAC key, pagerduty: key holding the routing key
+	Channel        string `yaml:"channel"`        // slack
+	Username       string `yaml:"username"`       // slack: user name of the messages, email: SMTP user name
+	Icon           string `yaml:"icon"`           // slack
+	Severity       string `yaml:"severity"`       // pagerduty
+	Host           string `yaml:"host"`           // email: SMTP server
+	Port           int    `yaml:"port"`           // email: SMTP port
+	From           string `yaml:"from"`           // email
+	To             string `yaml:"to"`             // email: comma separated list of recipients
+	Subject        string `yaml:"subject"`        // email: Go template of the subject
+	PasswordSecret string `yaml:"passwordSecret"` // email: key holding the SMTP password
+}
+
+var notifiers []Notifier
+var httpClient = &http.Client{Timeout: httpTimeout}
+
+// initNotifiers - Create the notification channels from the monitoring configuration
+func initNotifiers() error {
+	notifiers = nil
+	if monitorCfg.SlackWebhook != "" {
+		log.Print("monitoring | WARNING: monitoring.slackWebhook is deprecated, use a slack notification with the webhook URL in monitoring.notificationSecret")
+		notifiers = append(notifiers, &slackNotifier{
+			url:      monitorCfg.SlackWebhook,
+			channel:  monitorCfg.SlackChannel,
+			username: monitorCfg.SlackUsername,
+			icon:     monitorCfg.SlackIcon,
+			template: defaultTemplate,
+		})
+	}
+	for _, cfg := range monitorCfg.Notifications {
+		notifier, err := newNotifier(cfg)
+		if err != nil {
+			return fmt.Errorf("notification channel %s: %v", cfg.Type, err)
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	return nil
+}
+
+// newNotifier - Create the notification channel for the configuration
+func newNotifier(cfg notificationConfig) (Notifier, error) {
+	tmpl := cfg.Template
+	if tmpl == "" {
+		tmpl = monitorCfg.NotificationTemplate
+	}
+	if tmpl == "" {
+		tmpl = defaultTemplate
+	}
+	if _, err := template.New(cfg.Type).Parse(tmpl); err != nil {
+		return nil, fmt.Errorf("invalid template: %v", err)
+	}
+	switch cfg.Type {
+	case notifierSlack:
+		endpoint, err := readSecret(cfg.URLSecret, "slackWebhook")
+		if err != nil {
+			return nil, err
+		}
+		return &slackNotifier{url: endpoint, channel: cfg.Channel, username: cfg.Username, icon: cfg.Icon, template: tmpl}, nil
+	case notifierWebhook:
+		endpoint, err := readSecret(cfg.URLSecret, "webhookURL")
+		if err != nil {
+			return nil, err
+		}
+		key, err := readSecret(cfg.TokenSecret, "webhookSigningKey")
+		if err != nil {
+			return nil, err
+		}
+		return &webhookNotifier{url: endpoint, key: []byte(key), template: tmpl}, nil
+	case notifierPagerDuty:
+		routingKey, err := readSecret(cfg.TokenSecret, "pagerdutyRoutingKey")
+		if err != nil {
+			return nil, err
+		}
+		return newPagerDutyNotifier(routingKey, cfg.Severity, tmpl)
+	case notifierTeams:
+		endpoint, err := readSecret(cfg.URLSecret, "teamsWebhook")
+		if err != nil {
+			return nil, err
+		}
+		return &teamsNotifier{url: endpoint, template: tmpl}, nil
+	case notifierEmail:
+		return newEmailNotifier(cfg, tmpl)
+	}
+	return nil, fmt.Errorf("unknown notification channel type: %q", cfg.Type)
+}
+
+// Notify - Report a change of the VPN configuration to the notification channels
+func Notify(message string) {
+	notify(EventInfo, message)
+}
+
+// notify - Send the event to all of the notification channels.  Failures are logged, so one channel that is not
+// reachable does not stop the notifications to the other channels
+func notify(kind, message string) bool {
+	log.Printf("monitoring | %s", message)
+	event := Event{Kind: kind, Location: podLocationInfo, Message: message, Time: time.Now().UTC().Format(time.RFC3339)}
+	delivered := true
+	for _, notifier := range notifiers {
+		log.Printf("monitoring | Sending %s notification", notifier.Name())
+		if err := notifier.Send(event); err != nil {
+			log.Printf("monitoring | WARNING: Failed to send %s notification: %v", notifier.Name(), err)
+			delivered = false
+		}
+	}
+	return delivered
+}
+
+// render - Build the text of the event from the template of the channel
+func (event Event) render(tmpl string) (string, error) {
+	parsed, err := template.New("notification").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buffer bytes.Buffer
+	if err := parsed.Execute(&buffer, event); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// readSecret - Read a key of the notification secret.  defaultKey is used if the key name was not configured
+func readSecret(key, defaultKey string) (string, error) {
+	if key == "" {
+		key = defaultKey
+	}
+	value, err := os.ReadFile(filepath.Join(notificationSecretDir, filepath.Base(key))) // #nosec G304 only keys of the mounted secret are read
+	if err != nil {
+		return "", fmt.Errorf("key %s of monitoring.notificationSecret can not be read: %v", key, err)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// postJSON - Post the JSON encoding of the body to the URL
+func postJSON(endpoint string, body interface{}, headers map[string]string) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return post(endpoint, data, headers)
+}
+
+// post - Post the JSON data to the URL.  Status codes other than 2xx are an error.  The URL is not included in the
+// errors, since webhook URLs contain their credentials
+func post(endpoint string, data []byte, headers map[string]string) error {
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid URL")
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer response.Body.Close() // #nosec G307 safe to call close() here
+	log.Printf("monitoring | HTTP status code: %d", response.StatusCode)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("HTTP status code: %d", response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package monitoring
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	pagerDutyURL             = "https://events.pagerduty.com/v2/enqueue"
+	pagerDutyDefaultSeverity = "critical"
+	pagerDutySummaryLimit    = 1024
+)
+
+// pagerDutyEvent - Event of the PagerDuty Events API v2
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary  string `json:"summary"`
+	Source   string `json:"source"`
+	Severity string `json:"severity"`
+}
+
+// pagerDutyNotifier - Open a PagerDuty incident when the VPN goes down and resolve it when the VPN is up again.
+// Other notifications are not sent, so they do not page anyone
+type pagerDutyNotifier struct {
+	routingKey string
+	severity   string
+	template   string
+}
+
+func newPagerDutyNotifier(routingKey, severity, template string) (Notifier, error) {
+	switch severity {
+	case "":
+		severity = pagerDutyDefaultSeverity
+	case "critical", "error", "warning", "info":
+	default:
+		return nil, fmt.Errorf("invalid severity: %q", severity)
+	}
+	return &pagerDutyNotifier{routingKey: routingKey, severity: severity, template: template}, nil
+}
+
+func (n *pagerDutyNotifier) Name() string {
+	return notifierPagerDuty
+}
+
+func (n *pagerDutyNotifier) Send(event Event) error {
+	pdEvent := pagerDutyEvent{RoutingKey: n.routingKey, DedupKey: pagerDutyDedupKey()}
+	switch event.Kind {
+	case EventDown:
+		text, err := event.render(n.template)
+		if err != nil {
+			return err
+		}
+		if len(text) > pagerDutySummaryLimit {
+			text = text[:pagerDutySummaryLimit]
+		}
+		pdEvent.EventAction = "trigger"
+		pdEvent.Payload = &pagerDutyPayload{Summary: text, Source: monitorPodName, Severity: n.severity}
+	case EventUp:
+		pdEvent.EventAction = "resolve"
+	default:
+		return nil
+	}
+	return postJSON(pagerDutyURL, pdEvent, nil)
+}
+
+// pagerDutyDedupKey - Identify the incident of the VPN, so the VPN pod that replaces this one resolves the same
+// incident.  The suffixes that the deployment adds to the pod name are removed
+func pagerDutyDedupKey() string {
+	deployment := monitorPodName
+	if parts := strings.Split(monitorPodName, "-"); len(parts) > 2 {
+		deployment = strings.Join(parts[:len(parts)-2], "-")
+	}
+	return "strongswan/" + monitorClusterID + "/" + deployment
+}
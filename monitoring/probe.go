@@ -0,0 +1,232 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package monitoring
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported probe types
+const (
+	probeTCP = "tcp" // Connect to host:port
+	probeDNS = "dns" // Resolve a host name with a DNS server in the remote subnet
+	probeUDP = "udp" // Send a request to host:port and wait for the response
+)
+
+const (
+	defaultDNSPort = "53"
+)
+
+// probeConfig - Settings of a probe in monitoring.conf
+type probeConfig struct {
+	Type       string `yaml:"type"`
+	Target     string `yaml:"target"`     // host:port to test, dns: IP of the DNS server, the port defaults to 53
+	Query      string `yaml:"query"`      // dns: host name to resolve
+	Expect     string `yaml:"expect"`     // dns: comma separated list of IPs that must be in the answer, udp: text that must be in the response
+	Payload    string `yaml:"payload"`    // udp: request to send
+	PayloadHex string `yaml:"payloadHex"` // udp: request to send, hex encoded
+	Timeout    int    `yaml:"timeout"`    // Seconds that each attempt must complete in, default: monitoring.timeout
+	Interval   int    `yaml:"interval"`   // Seconds between runs of the probe, default: monitoring.delay
+	Retries    *int   `yaml:"retries"`    // Attempts after the first failure, default: 3
+}
+
+// probe - Probe and the result of its last run
+type probe struct {
+	probeConfig
+	expectIPs []net.IP
+	payload   []byte
+	timeout   time.Duration
+	interval  int64
+	retries   int
+	nextRun   int64
+	result    string
+	failed    bool
+}
+
+var probes []*probe
+
+// verifyProbes - validate the probes and set the defaults of the settings that were not configured
+func verifyProbes() {
+	probes = nil
+	for i, cfg := range monitorCfg.Probes {
+		p, err := newProbe(cfg)
+		if err != nil {
+			log.Fatalf("monitoring | ERROR: monitoring.probes[%d] is not valid: %v", i, err)
+		}
+		probes = append(probes, p)
+	}
+}
+
+// newProbe - create the probe for the configuration
+func newProbe(cfg probeConfig) (*probe, error) {
+	p := &probe{probeConfig: cfg, retries: defaultRetryTimes}
+	if cfg.Timeout < 0 {
+		return nil, fmt.Errorf("incorrect timeout: %d", cfg.Timeout)
+	} else if cfg.Timeout == 0 {
+		cfg.Timeout = monitorCfg.Timeout
+	}
+	p.timeout = time.Duration(cfg.Timeout) * time.Second
+	if cfg.Interval < 0 {
+		return nil, fmt.Errorf("incorrect interval: %d", cfg.Interval)
+	} else if cfg.Interval == 0 {
+		cfg.Interval = monitorCfg.Delay
+	}
+	p.interval = int64(cfg.Interval)
+	if cfg.Retries != nil {
+		if *cfg.Retries < 0 {
+			return nil, fmt.Errorf("incorrect retries: %d", *cfg.Retries)
+		}
+		p.retries = *cfg.Retries
+	}
+
+	if cfg.Type == probeDNS {
+		if _, _, err := net.SplitHostPort(cfg.Target); err != nil {
+			p.Target = net.JoinHostPort(cfg.Target, defaultDNSPort)
+		}
+	}
+	if err := verifyTarget(p.Target); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case probeTCP:
+	case probeDNS:
+		if cfg.Query == "" || net.ParseIP(cfg.Query) != nil {
+			return nil, fmt.Errorf("query must be a host name: %q", cfg.Query)
+		}
+		if cfg.Expect != "" {
+			for _, address := range strings.Split(cfg.Expect, ",") {
+				ip := net.ParseIP(strings.TrimSpace(address))
+				if ip == nil {
+					return nil, fmt.Errorf("expect contains an invalid IP address: %s", address)
+				}
+				p.expectIPs = append(p.expectIPs, ip)
+			}
+		}
+	case probeUDP:
+		if cfg.Payload != "" && cfg.PayloadHex != "" {
+			return nil, fmt.Errorf("only one of payload and payloadHex can be set")
+		}
+		p.payload = []byte(cfg.Payload)
+		if cfg.PayloadHex != "" {
+			payload, err := hex.DecodeString(cfg.PayloadHex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid payloadHex: %v", err)
+			}
+			p.payload = payload
+		}
+		if len(p.payload) == 0 {
+			return nil, fmt.Errorf("payload or payloadHex must be set")
+		}
+	default:
+		return nil, fmt.Errorf("unknown probe type: %q", cfg.Type)
+	}
+	return p, nil
+}
+
+// verifyTarget - check that the target is host:port and that the port is within the acceptable port range
+func verifyTarget(target string) error {
+	host, portString, err := net.SplitHostPort(target)
+	if err != nil {
+		return fmt.Errorf("invalid target %q: %v", target, err)
+	}
+	if host == "" {
+		return fmt.Errorf("invalid target %q: missing host", target)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil || port > 65535 || port < 1 {
+		return fmt.Errorf("invalid target %q: port number not in the range: 1-65535", target)
+	}
+	return nil
+}
+
+// startProbes - schedule the first run of all of the probes
+func startProbes(runTime int64) {
+	for _, p := range probes {
+		p.nextRun = runTime
+	}
+}
+
+// resetProbes - forget the results of the probes, so the next result of each probe is reported
+func resetProbes() {
+	for _, p := range probes {
+		p.result = ""
+		p.failed = false
+	}
+}
+
+// probesHealthy - did the last run of all of the probes succeed
+func probesHealthy() bool {
+	for _, p := range probes {
+		if p.failed {
+			return false
+		}
+	}
+	return true
+}
+
+// runProbes - run the probes whose interval has expired and return the results that have changed
+func runProbes() string {
+	message := ""
+	for _, p := range probes {
+		if time.Now().Unix() < p.nextRun {
+			continue
+		}
+		result := p.run()
+		p.nextRun = time.Now().Unix() + p.interval
+		if p.result != result {
+			p.result = result
+			message += result
+		}
+	}
+	return message
+}
+
+// run - run the probe, repeating 'retries' times if there is an issue, and return the result
+func (p *probe) run() string {
+	var err error
+	result := ""
+	switch p.Type {
+	case probeTCP:
+		result += fmt.Sprintf("Connecting to remote %s. ", p.Target)
+	case probeDNS:
+		result += fmt.Sprintf("Resolving %s with remote DNS server %s. ", p.Query, p.Target)
+	case probeUDP:
+		result += fmt.Sprintf("Sending UDP request to remote %s. ", p.Target)
+	}
+	for attempt := 0; attempt <= p.retries; attempt++ {
+		switch p.Type {
+		case probeTCP:
+			err = tcpTest(p.Target, p.timeout)
+		case probeDNS:
+			err = dnsTest(p.Target, p.Query, p.expectIPs, p.timeout)
+		case probeUDP:
+			err = udpTest(p.Target, p.payload, p.Expect, p.timeout)
+		}
+		if err == nil {
+			break
+		}
+	}
+	p.failed = err != nil
+	if err != nil {
+		result += fmt.Sprintf("Failed with: %v\n", err)
+	} else {
+		result += "Success\n"
+	}
+	log.Printf("monitoring | %s", result)
+	return result
+}
@@ -0,0 +1,56 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package monitoring
+
+import (
+	"strings"
+)
+
+// Theme colors of the Teams message for each kind of event
+var teamsColors = map[string]string{
+	EventInfo: "0076D7",
+	EventUp:   "2EB886",
+	EventDown: "D00000",
+}
+
+// teamsMessage - Message card posted to a Microsoft Teams incoming webhook
+type teamsMessage struct {
+	Type       string `json:"@type"`
+	Context    string `json:"@context"`
+	Summary    string `json:"summary"`
+	ThemeColor string `json:"themeColor"`
+	Text       string `json:"text"`
+}
+
+// teamsNotifier - Post the notifications to a Microsoft Teams channel
+type teamsNotifier struct {
+	url      string
+	template string
+}
+
+func (n *teamsNotifier) Name() string {
+	return notifierTeams
+}
+
+func (n *teamsNotifier) Send(event Event) error {
+	text, err := event.render(n.template)
+	if err != nil {
+		return err
+	}
+	message := teamsMessage{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		Summary:    "strongSwan VPN " + event.Kind,
+		ThemeColor: teamsColors[event.Kind],
+		Text:       strings.ReplaceAll(text, "\n", "\n\n"), // Teams needs a blank line to start a new line
+	}
+	return postJSON(n.url, message, nil)
+}
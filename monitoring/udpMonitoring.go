@@ -0,0 +1,42 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package monitoring
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+)
+
+// udpTest - send the request to host:port and wait for a response that contains the expected text
+func udpTest(target string, payload []byte, expect string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("udp", target, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close() // #nosec G307 safe to call close() here
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if _, err = conn.Write(payload); err != nil {
+		return err
+	}
+	response := make([]byte, 65535)
+	length, err := conn.Read(response)
+	if err != nil {
+		return err
+	}
+	if expect != "" && !bytes.Contains(response[:length], []byte(expect)) {
+		return fmt.Errorf("response does not contain %q", expect)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package monitoring
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Headers of the generic webhook.  The signature is the hex HMAC-SHA256 of "<timestamp>.<body>", so the receiver
+// can verify the sender and reject old requests that are replayed
+const (
+	webhookSignatureHeader = "X-Strongswan-Signature"
+	webhookTimestampHeader = "X-Strongswan-Timestamp"
+)
+
+// webhookMessage - Body posted to the generic webhook
+type webhookMessage struct {
+	Event    string `json:"event"`
+	Location string `json:"location"`
+	Message  string `json:"message"`
+	Text     string `json:"text"`
+	Time     string `json:"time"`
+}
+
+// webhookNotifier - Post the notifications to any HTTP endpoint, signed with a shared key
+type webhookNotifier struct {
+	url      string
+	key      []byte
+	template string
+}
+
+func (n *webhookNotifier) Name() string {
+	return notifierWebhook
+}
+
+func (n *webhookNotifier) Send(event Event) error {
+	text, err := event.render(n.template)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(webhookMessage{Event: event.Kind, Location: event.Location, Message: event.Message, Text: text, Time: event.Time})
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, n.key)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(data)
+	headers := map[string]string{
+		webhookSignatureHeader: "sha256=" + hex.EncodeToString(mac.Sum(nil)),
+		webhookTimestampHeader: timestamp,
+	}
+	return post(n.url, data, headers)
+}
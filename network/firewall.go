@@ -0,0 +1,237 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package network
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Supported firewall backends
+const (
+	FirewallAuto           = "auto"            // Use the backend that already holds the rules on the node
+	FirewallIptablesLegacy = "iptables-legacy" // iptables using the legacy x_tables kernel interface
+	FirewallIptablesNft    = "iptables-nft"    // iptables using the nf_tables kernel interface
+	FirewallNftables       = "nftables"        // Native nftables rules in a dedicated table
+)
+
+// NAT targets used by the VPN rules
+const (
+	NATTargetMasquerade = "MASQUERADE"
+	NATTargetNetmap     = "NETMAP"
+	NATTargetSNAT       = "SNAT"
+)
+
+// NAT chains used by the VPN rules
+const (
+	NATChainPostrouting = "POSTROUTING"
+	NATChainPrerouting  = "PREROUTING"
+)
+
+// natChains - Built-in chains that the VPN rules are placed under
+var natChains = []string{NATChainPostrouting, NATChainPrerouting}
+
+// natCommentPrefix - All of the VPN rules are tagged with "strongswan:<release>:<rule id>" comments
+const natCommentPrefix = "strongswan"
+
+// NATRule - A single rule in the nat table
+type NATRule struct {
+	Chain    string // NATChainPostrouting or NATChainPrerouting
+	Source   string // Source IP / subnet to match (optional)
+	Dest     string // Destination IP / subnet to match (optional)
+	Protocol string // Protocol to match (optional)
+	Target   string // NATTargetMasquerade, NATTargetNetmap or NATTargetSNAT
+	To       string // Translated IP / subnet (not used for MASQUERADE)
+}
+
+// Firewall - Implementation used to apply and check the nat rules on the node.  The rules are placed in
+// chains owned by the VPN (ex: STRONGSWAN-POSTROUTING) and each rule is tagged with the release that owns it
+type Firewall interface {
+	Name() string
+	ApplyNATRules(chain, owner string, rules []NATRule) error
+	NATRuleExists(owner string, rule NATRule) (bool, error)
+}
+
+var firewall Firewall = newIptablesFirewall(FirewallIptablesLegacy)
+
+// natRules - Rules that should be defined for each owner (release) and built-in chain
+var natRules = map[string]map[string][]NATRule{}
+var natMutex sync.Mutex
+
+// firewallLockFile - File locked while the chains are updated.  The chains are shared by the route daemons of all of
+// the releases on the node, so each one has to read and apply them without the others changing them in between
+var firewallLockFile string
+
+// String - Display the rule in iptables format
+func (rule NATRule) String() string {
+	return rule.Chain + " " + strings.Join(rule.iptablesArgs(""), " ")
+}
+
+// ipv6 - Is the rule an IPv6 (NAT66) rule
+func (rule NATRule) ipv6() bool {
+	return IsIPv6(rule.Source) || IsIPv6(rule.Dest) || IsIPv6(rule.To)
+}
+
+// natRulesOfFamily - Select the IPv4 or IPv6 rules from the list
+func natRulesOfFamily(rules []NATRule, ipv6 bool) []NATRule {
+	selected := []NATRule{}
+	for _, rule := range rules {
+		if rule.ipv6() == ipv6 {
+			selected = append(selected, rule)
+		}
+	}
+	return selected
+}
+
+// comment - Comment used to tag the rule with the owner (release) and identify the rule
+func (rule NATRule) comment(owner string) string {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(rule.String()))
+	return fmt.Sprintf("%s:%08x", ownerComment(owner), hash.Sum32())
+}
+
+// ownerComment - Prefix of the comments on all of the rules that belong to the owner
+func ownerComment(owner string) string {
+	return natCommentPrefix + ":" + owner
+}
+
+// AddNATRule - Add the rule to the set of rules owned by the release and apply the updated chain
+func AddNATRule(owner string, rule NATRule) error {
+	natMutex.Lock()
+	defer natMutex.Unlock()
+	rules := ownerNATRules(owner)
+	found := false
+	for _, existing := range rules[rule.Chain] {
+		if existing == rule {
+			found = true
+		}
+	}
+	if !found {
+		rules[rule.Chain] = append(rules[rule.Chain], rule)
+	}
+	// The whole chain is always applied so rules left over from a previous run are replaced
+	return applyNATRules(rule.Chain, owner, rules[rule.Chain])
+}
+
+// DeleteNATRule - Remove the rule from the set of rules owned by the release and apply the updated chain
+func DeleteNATRule(owner string, rule NATRule) error {
+	natMutex.Lock()
+	defer natMutex.Unlock()
+	rules := ownerNATRules(owner)
+	kept := []NATRule{}
+	for _, existing := range rules[rule.Chain] {
+		if existing != rule {
+			kept = append(kept, existing)
+		}
+	}
+	rules[rule.Chain] = kept
+	return applyNATRules(rule.Chain, owner, kept)
+}
+
+// RemoveNATRules - Remove all of the rules owned by the release.  Rules of other releases are not touched
+func RemoveNATRules(owner string) error {
+	natMutex.Lock()
+	defer natMutex.Unlock()
+	delete(natRules, owner)
+	for _, chain := range natChains {
+		if err := applyNATRules(chain, owner, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyNATRules - Apply the rules of the owner while holding the firewall lock.  Caller must hold natMutex
+func applyNATRules(chain, owner string, rules []NATRule) error {
+	unlock, err := lockFirewall()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return firewall.ApplyNATRules(chain, owner, rules)
+}
+
+// ownerNATRules - Rules for the owner, indexed by built-in chain.  Caller must hold natMutex
+func ownerNATRules(owner string) map[string][]NATRule {
+	rules, ok := natRules[owner]
+	if !ok {
+		rules = map[string][]NATRule{}
+		natRules[owner] = rules
+	}
+	return rules
+}
+
+// SetFirewallBackend - Select the implementation used for nat rules.  "auto" detects what the node is already using
+func SetFirewallBackend(name string) error {
+	if name == "" || name == FirewallAuto {
+		name = detectFirewallBackend()
+	}
+	switch name {
+	case FirewallIptablesLegacy, FirewallIptablesNft:
+		firewall = newIptablesFirewall(name)
+	case FirewallNftables:
+		firewall = &nftablesFirewall{}
+	default:
+		return fmt.Errorf("unknown firewall backend: %s  Valid choices: [ %s, %s, %s, %s ]", name, FirewallAuto, FirewallIptablesLegacy, FirewallIptablesNft, FirewallNftables)
+	}
+	log.Printf("Using firewall backend: %s", name)
+	return nil
+}
+
+// SetFirewallLockFile - Lock the file on the node while the nat rules are applied.  The route daemons of the other
+// releases on the node must use the same file
+func SetFirewallLockFile(file string) {
+	firewallLockFile = file
+}
+
+// UseFirewall - Use the specified Firewall implementation
+func UseFirewall(fw Firewall) {
+	firewall = fw
+}
+
+// detectFirewallBackend - Determine which backend holds the existing rules (kube-proxy, calico) on the node
+func detectFirewallBackend() string {
+	legacyRules := countIptablesRules("/usr/sbin/iptables-legacy-save")
+	nftRules := countIptablesRules("/usr/sbin/iptables-nft-save")
+	log.Printf("Firewall detection: iptables-legacy rules: %d, iptables-nft rules: %d", legacyRules, nftRules)
+	switch {
+	case nftRules > legacyRules:
+		return FirewallIptablesNft
+	case legacyRules > 0:
+		return FirewallIptablesLegacy
+	}
+	// Neither iptables flavor is in use.  If there is a native nftables ruleset, use it
+	outBytes, err := exec.Command("sudo", "/usr/sbin/nft", "list", "tables").CombinedOutput()
+	if err == nil && len(strings.TrimSpace(string(outBytes))) > 0 {
+		log.Printf("Firewall detection: nftables tables: %s", strings.Join(strings.Fields(string(outBytes)), " "))
+		return FirewallNftables
+	}
+	return FirewallIptablesLegacy
+}
+
+// countIptablesRules - Count the number of rules listed by the iptables-save command
+func countIptablesRules(saveCommand string) int {
+	outBytes, err := exec.Command("sudo", saveCommand).CombinedOutput() // #nosec G204 variable is a fixed constant
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, line := range strings.Split(string(outBytes), "\n") {
+		if strings.HasPrefix(line, "-A ") {
+			count++
+		}
+	}
+	return count
+}
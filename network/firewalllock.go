@@ -0,0 +1,40 @@
+//go:build linux
+
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package network
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFirewall - Take the exclusive lock on the firewall lock file.  The lock is released by the returned function, or
+// by the kernel if the process exits.  Nothing is locked if no lock file is set
+func lockFirewall() (func(), error) {
+	if firewallLockFile == "" {
+		return func() {}, nil
+	}
+	// The file is created by the hostPath volume and owned by root, so it is only opened for reading
+	file, err := os.Open(firewallLockFile) // #nosec G304 file name is a fixed constant
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the firewall lock %s: %v", firewallLockFile, err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close() // #nosec G104 the lock error is returned
+		return nil, fmt.Errorf("unable to lock %s: %v", firewallLockFile, err)
+	}
+	return func() {
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close() // #nosec G104 closing a read only file
+	}, nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package network
+
+// lockFirewall - The route daemon only runs on Linux worker nodes, so there is nothing to lock
+func lockFirewall() (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,191 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ipCommandBackend - RouteBackend implementation that runs "sudo /sbin/ip" and parses the output
+type ipCommandBackend struct{}
+
+// GetDeviceToAddress - Get the device used by the routes in the main table that go to / through the IP address
+func (ipCommandBackend) GetDeviceToAddress(ipAddr string) (string, error) {
+	ip := net.ParseIP(ipAddr)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address: %s", ipAddr)
+	}
+	outBytes, err := exec.Command("sudo", "/sbin/ip", familyFlag(ipAddr), "route", "list").CombinedOutput() // #nosec G204 variable is a fixed constant
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve routing table: %v", err)
+	}
+	device := ""
+	for _, line := range strings.Split(string(outBytes), "\n") {
+		words := strings.Fields(line)
+		if len(words) < 2 {
+			continue
+		}
+		route := parseRoute(words, IsIPv6(ipAddr))
+		if ip.Equal(net.ParseIP(route.Via)) || ip.Equal(net.ParseIP(strings.Split(route.Dest, "/")[0])) {
+			device = route.Dev
+		}
+	}
+	return device, nil
+}
+
+// GetRoutes - Retrieve the IPv4 and IPv6 routes defined in a specific routing table
+func (ipCommandBackend) GetRoutes(routeTable string) ([]RoutingInfo, error) {
+	var routes []RoutingInfo
+	for _, family := range ipFamilies() {
+		outBytes, err := exec.Command("sudo", "/sbin/ip", family, "route", "list", "table", routeTable).CombinedOutput() // #nosec G204 variable is built from fixed constants and network information, user can not override
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve %s routing table %s: %v", family, routeTable, err)
+		}
+		for _, entry := range strings.Split(string(outBytes), "\n") {
+			words := strings.Fields(entry)
+			if len(words) >= 2 {
+				route := parseRoute(words, family == "-6")
+				if routeTable != "main" {
+					route.Table = routeTable
+				}
+				routes = append(routes, route)
+			}
+		}
+	}
+	return routes, nil
+}
+
+// GetRouteToAddress - Retrieve the route that the kernel would use to send traffic to the IP address
+func (ipCommandBackend) GetRouteToAddress(ipAddr string) (RoutingInfo, error) {
+	outBytes, err := exec.Command("sudo", "/sbin/ip", "route", "get", ipAddr).CombinedOutput() // #nosec G204 variable is built from fixed constants and network information, user can not override
+	if err != nil {
+		return RoutingInfo{}, fmt.Errorf("failed to get route to %s: %v - %s", ipAddr, err, strings.TrimSpace(string(outBytes)))
+	}
+	words := strings.Fields(strings.Split(string(outBytes), "\n")[0])
+	if len(words) == 0 {
+		return RoutingInfo{}, fmt.Errorf("no route returned for %s", ipAddr)
+	}
+	return parseRoute(words, IsIPv6(ipAddr)), nil
+}
+
+// GetRules - Retrieve the list of IPv4 and IPv6 ip rules
+func (ipCommandBackend) GetRules() ([]RuleInfo, error) {
+	var rules []RuleInfo
+	for _, family := range ipFamilies() {
+		outBytes, err := exec.Command("sudo", "/sbin/ip", family, "rule", "list").CombinedOutput() // #nosec G204 variable is a fixed constant
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve %s routing rules: %v", family, err)
+		}
+		for _, line := range strings.Split(string(outBytes), "\n") {
+			// Format of each line:  "210:	from all lookup 210"
+			words := strings.Fields(line)
+			if len(words) < 2 || !strings.HasSuffix(words[0], ":") {
+				continue
+			}
+			priority, err := strconv.Atoi(strings.TrimSuffix(words[0], ":"))
+			if err != nil {
+				continue
+			}
+			rule := RuleInfo{Priority: priority}
+			for i, word := range words {
+				if i == len(words)-1 {
+					break
+				}
+				switch word {
+				case "from":
+					rule.From = hostAddress(words[i+1])
+					if rule.From == "all" && family == "-6" {
+						rule.From = RuleFromAllIPv6
+					}
+				case "lookup", "table":
+					rule.Table = words[i+1]
+				}
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// UpdateRoute - add/del/replace routing info for a subnet
+func (ipCommandBackend) UpdateRoute(addDelAction NetAddDelAction, subnet, routeInfo string) error {
+	routeCommand := fmt.Sprintf("/sbin/ip %s route %s %s %s", familyFlag(subnet), addDelAction, subnet, routeInfo)
+	words := strings.Fields(routeCommand)
+	outBytes, err := exec.Command("sudo", words...).CombinedOutput() // #nosec G204 variable is built from fixed constants and network information, user can not override
+	if err != nil {
+		return fmt.Errorf("failed <%s>: %v - %s", routeCommand, err, strings.TrimSpace(string(outBytes)))
+	}
+	return nil
+}
+
+// UpdateRule - add/del the rule that sends traffic from the source to the routing table
+func (ipCommandBackend) UpdateRule(addDelAction NetAddDelAction, fromSource, routeTable, priority string) error {
+	ruleCommand := fmt.Sprintf("%s rule %s from %s table %s", familyFlag(fromSource), addDelAction, fromSource, routeTable)
+	if addDelAction == NetActionAdd {
+		ruleCommand += " prior " + priority
+	}
+	words := strings.Fields("/sbin/ip " + ruleCommand)
+	outBytes, err := exec.Command("sudo", words...).CombinedOutput() // #nosec G204 variable is built from fixed constants and network information, user can not override
+	if err != nil {
+		return fmt.Errorf("failed <ip %s>: %v - %s", ruleCommand, err, strings.TrimSpace(string(outBytes)))
+	}
+	return nil
+}
+
+// familyFlag - "-4" or "-6" option of the ip command for the address / subnet
+func familyFlag(addr string) string {
+	if IsIPv6(addr) {
+		return "-6"
+	}
+	return "-4"
+}
+
+// ipFamilies - Options of the ip command for the IP families used on the node.  IPv6 is skipped if it is disabled
+func ipFamilies() []string {
+	if IPv6Enabled() {
+		return []string{"-4", "-6"}
+	}
+	return []string{"-4"}
+}
+
+// parseRoute - Convert the words of a single "ip route" output line in to a RoutingInfo object.  Host routes get a
+// /32 (IPv4) or /128 (IPv6) suffix and the IPv6 default route is "::/0"
+func parseRoute(words []string, ipv6 bool) RoutingInfo {
+	var route = RoutingInfo{}
+	route.Dest = words[0]
+	switch {
+	case route.Dest == "default" && ipv6:
+		route.Dest = RuleFromAllIPv6
+	case route.Dest == "default" || strings.Contains(route.Dest, "/"):
+	case ipv6:
+		route.Dest += "/128"
+	default:
+		route.Dest += "/32"
+	}
+	for n, value := range words {
+		if n == len(words)-1 {
+			break
+		}
+		switch value {
+		case "via":
+			route.Via = words[n+1]
+		case "dev":
+			route.Dev = words[n+1]
+		case "table":
+			route.Table = words[n+1]
+		}
+	}
+	return route
+}
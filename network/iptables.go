@@ -0,0 +1,144 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package network
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// iptablesFirewall - Firewall implementation that runs iptables-legacy or iptables-nft.  The IPv6 rules are applied
+// by a second instance that runs the matching ip6tables commands
+type iptablesFirewall struct {
+	name    string
+	command string
+	restore string
+	save    string
+	ipv6    *iptablesFirewall // ip6tables flavor, nil for the IPv6 instance itself
+}
+
+// newIptablesFirewall - Create the iptables firewall for the "iptables-legacy" or "iptables-nft" backend
+func newIptablesFirewall(name string) *iptablesFirewall {
+	flavor := strings.TrimPrefix(name, "iptables-")
+	ipv6 := &iptablesFirewall{name: name, command: "/usr/sbin/ip6tables-" + flavor, restore: "/usr/sbin/ip6tables-" + flavor + "-restore", save: "/usr/sbin/ip6tables-" + flavor + "-save"}
+	return &iptablesFirewall{name: name, command: "/usr/sbin/iptables-" + flavor, restore: "/usr/sbin/iptables-" + flavor + "-restore", save: "/usr/sbin/iptables-" + flavor + "-save", ipv6: ipv6}
+}
+
+// Name - Name of the firewall backend
+func (fw *iptablesFirewall) Name() string {
+	return fw.name
+}
+
+// ApplyNATRules - Replace the IPv4 and IPv6 rules of the owner in the STRONGSWAN-<chain> chains.  The IPv6 rules are
+// only applied if IPv6 is enabled on the node
+func (fw *iptablesFirewall) ApplyNATRules(chain, owner string, rules []NATRule) error {
+	if err := fw.applyNATRules(chain, owner, natRulesOfFamily(rules, false)); err != nil {
+		return err
+	}
+	if !IPv6Enabled() {
+		return nil
+	}
+	return fw.ipv6.applyNATRules(chain, owner, natRulesOfFamily(rules, true))
+}
+
+// applyNATRules - Replace the rules of the owner in the STRONGSWAN-<chain> chain with a single iptables-restore.
+// Rules that belong to other releases are preserved.  The jump from the built-in chain is added if missing
+func (fw *iptablesFirewall) applyNATRules(chain, owner string, rules []NATRule) error {
+	ownedChain := iptablesChain(chain)
+	outBytes, err := exec.Command("sudo", fw.save, "-t", "nat").CombinedOutput() // #nosec G204 variable is a fixed constant
+	if err != nil {
+		return fmt.Errorf("failed <%s -t nat>: %v - %s", filepath.Base(fw.save), err, strings.TrimSpace(string(outBytes)))
+	}
+	ruleset := "*nat\n:" + ownedChain + " - [0:0]\n"
+	for _, line := range strings.Split(string(outBytes), "\n") {
+		if strings.HasPrefix(line, "-A "+ownedChain+" ") && !strings.Contains(line, ownerComment(owner)+":") {
+			ruleset += line + "\n"
+		}
+	}
+	for _, rule := range rules {
+		ruleset += "-A " + ownedChain + " " + strings.Join(rule.iptablesArgs(rule.comment(owner)), " ") + "\n"
+	}
+	ruleset += "COMMIT\n"
+	// --noflush only flushes the chains listed in the ruleset, so the rest of the nat table is not touched.  --wait
+	// waits for the xtables lock held by the other users of iptables on the node (kube-proxy, calico)
+	name := filepath.Base(fw.restore)
+	log.Printf("%s --noflush --wait\n%s", name, ruleset)
+	cmd := exec.Command("sudo", fw.restore, "--noflush", "--wait") // #nosec G204 variable is a fixed constant
+	cmd.Stdin = strings.NewReader(ruleset)
+	outBytes, err = cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed <%s --noflush --wait>: %v - %s", name, err, strings.TrimSpace(string(outBytes)))
+	}
+	jump := []string{chain, "-m", "comment", "--comment", natCommentPrefix, "-j", ownedChain}
+	if fw.check(append([]string{"-t", "nat", "-C"}, jump...)...) {
+		return nil
+	}
+	return fw.run(append([]string{"-t", "nat", "-A"}, jump...)...)
+}
+
+// NATRuleExists - Is the rule defined in the STRONGSWAN-<chain> chain and is the chain reachable from the built-in chain
+func (fw *iptablesFirewall) NATRuleExists(owner string, rule NATRule) (bool, error) {
+	if rule.ipv6() && fw.ipv6 != nil {
+		return fw.ipv6.NATRuleExists(owner, rule)
+	}
+	ownedChain := iptablesChain(rule.Chain)
+	if !fw.check("-t", "nat", "-C", rule.Chain, "-m", "comment", "--comment", natCommentPrefix, "-j", ownedChain) {
+		return false, nil
+	}
+	return fw.check(append([]string{"-t", "nat", "-C", ownedChain}, rule.iptablesArgs(rule.comment(owner))...)...), nil
+}
+
+// check - Run an iptables -C command and return whether the rule exists
+func (fw *iptablesFirewall) check(args ...string) bool {
+	err := exec.Command("sudo", append([]string{fw.command, "-w"}, args...)...).Run() // #nosec G204 variable is built from fixed constants and network information, user can not override
+	return err == nil
+}
+
+// run - Run iptables command helper routine
+func (fw *iptablesFirewall) run(args ...string) error {
+	name := filepath.Base(fw.command)
+	log.Printf("%s %s", name, strings.Join(args, " "))
+	outBytes, err := exec.Command("sudo", append([]string{fw.command, "-w"}, args...)...).CombinedOutput() // #nosec G204 variable is built from fixed constants and network information, user can not override
+	if err != nil {
+		return fmt.Errorf("failed <%s %s>: %v - %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(outBytes)))
+	}
+	return nil
+}
+
+// iptablesChain - Name of the chain owned by the VPN for the built-in chain
+func iptablesChain(chain string) string {
+	return "STRONGSWAN-" + chain
+}
+
+// iptablesArgs - Build the iptables match / target arguments for the rule (without the chain).  Comment is optional
+func (rule NATRule) iptablesArgs(comment string) []string {
+	args := []string{}
+	if rule.Source != "" {
+		args = append(args, "-s", rule.Source)
+	}
+	if rule.Dest != "" {
+		args = append(args, "-d", rule.Dest)
+	}
+	if rule.Protocol != "" {
+		args = append(args, "-p", rule.Protocol)
+	}
+	if comment != "" {
+		args = append(args, "-m", "comment", "--comment", comment)
+	}
+	args = append(args, "-j", rule.Target)
+	if rule.Target != NATTargetMasquerade {
+		args = append(args, "--to", rule.To)
+	}
+	return args
+}
@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"os"
 	"os/exec"
 	"strings"
 )
@@ -28,11 +29,17 @@ const (
 
 	// NetActionDelete - Flag to indicate routes/rules should be deleted
 	NetActionDelete NetAddDelAction = "del"
+
+	// NetActionReplace - Flag to indicate routes should be replaced (added or updated in place)
+	NetActionReplace NetAddDelAction = "replace"
 )
 
-// RoutingInfo - only need to store destination, via gateway, and dev interface being used
+// RuleFromAllIPv6 - Source of the IPv6 rule that sends all traffic to a routing table.  "all" is the IPv4 rule
+const RuleFromAllIPv6 = "::/0"
+
+// RoutingInfo - only need to store destination, via gateway, dev interface being used and the routing table
 type RoutingInfo struct {
-	Dest, Via, Dev string
+	Dest, Via, Dev, Table string
 }
 
 // ConfigureSubnetNAT - Configure a local or remote NAT table
@@ -41,55 +48,81 @@ type RoutingInfo struct {
 // The list of subnets that will be "subject" to the rules. ie, the subnets that will SEE the remapped IPs
 // Typically this is the "rightSubnet" list when applying localSubnetNAT rules, and the "leftSubnet"
 // when applying remoteSubnetNAT rules
-func ConfigureSubnetNAT(rulesNAT, subnetList string) {
+// owner - The release that the rules belong to
+// Rules are only created between subnets of the same IP family (NAT44 or NAT66)
+func ConfigureSubnetNAT(owner, rulesNAT, subnetList string) {
 	for _, subject := range strings.Split(subnetList, ",") {
 		for _, rule := range strings.Split(rulesNAT, ",") {
 			ruleSplit := strings.Split(rule, "=")
 			original := ruleSplit[0]
 			mapped := ruleSplit[1]
-			if !strings.HasSuffix(original, "/32") && strings.HasSuffix(mapped, "/32") {
+			if IsIPv6(original) != IsIPv6(subject) {
+				continue
+			}
+			if !isHostSubnet(original) && isHostSubnet(mapped) {
 				singleIP := strings.Split(mapped, "/")[0]
-				command := fmt.Sprintf("-t nat -A POSTROUTING -s %s -d %s -j SNAT --to %s", original, subject, singleIP)
-				ipTablesRun(command)
+				addNATRule(owner, NATRule{Chain: NATChainPostrouting, Source: original, Dest: subject, Target: NATTargetSNAT, To: singleIP})
 			} else {
-				command := fmt.Sprintf("-t nat -A POSTROUTING -s %s -d %s -j NETMAP --to %s", original, subject, mapped)
-				ipTablesRun(command)
-				command = fmt.Sprintf("-t nat -A PREROUTING -s %s -d %s -j NETMAP --to %s", subject, mapped, original)
-				ipTablesRun(command)
+				addNATRule(owner, NATRule{Chain: NATChainPostrouting, Source: original, Dest: subject, Target: NATTargetNetmap, To: mapped})
+				addNATRule(owner, NATRule{Chain: NATChainPrerouting, Source: subject, Dest: mapped, Target: NATTargetNetmap, To: original})
 			}
 		}
 	}
 }
 
-// ConfigureSingleSourceIP - Configure single source IP
-func ConfigureSingleSourceIP(localSubnet, remoteSubnet string) {
-	if !strings.HasSuffix(localSubnet, "/32") || strings.Contains(localSubnet, ",") {
-		log.Printf("WARNING: The configuration option local.subnet: %s is not a single /32 subnet.  Single source IP is not enabled", localSubnet)
-		return
+// ConfigureSingleSourceIP - Configure single source IP.  Local subnet can be a single IPv4 and / or IPv6 address
+func ConfigureSingleSourceIP(owner, localSubnet, remoteSubnet string) {
+	singleIPs := map[bool]string{}
+	for _, local := range strings.Split(localSubnet, ",") {
+		if !isHostSubnet(local) || singleIPs[IsIPv6(local)] != "" {
+			log.Printf("WARNING: The configuration option local.subnet: %s is not a single /32 or /128 subnet for each IP family.  Single source IP is not enabled", localSubnet)
+			return
+		}
+		singleIPs[IsIPv6(local)] = strings.Split(local, "/")[0]
+	}
+	for _, subnet := range strings.Split(remoteSubnet, ",") {
+		if singleIP := singleIPs[IsIPv6(subnet)]; singleIP != "" {
+			addNATRule(owner, NATRule{Chain: NATChainPostrouting, Dest: subnet, Target: NATTargetSNAT, To: singleIP})
+		}
 	}
-	singleIP := strings.Split(localSubnet, "/")[0]
-	command := fmt.Sprintf("-t nat -A POSTROUTING -d %s -j SNAT --to %s", remoteSubnet, singleIP)
-	ipTablesRun(command)
 }
 
 // ConfigureSNAT - Configure SNAT rules on worker nodes
-func ConfigureSNAT(addDelAction NetAddDelAction, remoteGateway, vpnPodIP, localBalancerIP string) {
-	action := "A"
+func ConfigureSNAT(owner string, addDelAction NetAddDelAction, remoteGateway, vpnPodIP, localBalancerIP string) {
+	rule := SNATRule(remoteGateway, vpnPodIP, localBalancerIP)
 	if addDelAction == NetActionDelete {
-		action = "D"
+		if err := DeleteNATRule(owner, rule); err != nil {
+			log.Printf("WARNING: %v", err)
+		}
+		return
+	}
+	addNATRule(owner, rule)
+}
+
+// SNATRuleExists - Is the SNAT rule created by ConfigureSNAT currently defined on the worker node
+func SNATRuleExists(owner, remoteGateway, vpnPodIP, localBalancerIP string) bool {
+	found, err := firewall.NATRuleExists(owner, SNATRule(remoteGateway, vpnPodIP, localBalancerIP))
+	if err != nil {
+		log.Printf("WARNING: %v", err)
 	}
-	command := ""
+	return found
+}
+
+// SNATRule - Build the POSTROUTING rule used by ConfigureSNAT
+func SNATRule(remoteGateway, vpnPodIP, localBalancerIP string) NATRule {
 	if vpnPodIP != "" {
-		command = fmt.Sprintf("-t nat -%s POSTROUTING -d %s -s %s -p udp -j SNAT --to %s", action, remoteGateway, vpnPodIP, localBalancerIP)
-	} else {
-		command = fmt.Sprintf("-t nat -%s POSTROUTING -d %s -j MASQUERADE", action, remoteGateway)
+		return NATRule{Chain: NATChainPostrouting, Source: vpnPodIP, Dest: remoteGateway, Protocol: "udp", Target: NATTargetSNAT, To: localBalancerIP}
 	}
-	ipTablesRun(command)
+	return NATRule{Chain: NATChainPostrouting, Dest: remoteGateway, Target: NATTargetMasquerade}
 }
 
 // DeleteConntrackEntry - Delete stale conntrack entry
 func DeleteConntrackEntry(remoteGateway, localBalancerIP string) {
-	command := fmt.Sprintf("/usr/sbin/conntrack -D -s %s -d %s -p udp", remoteGateway, localBalancerIP)
+	family := "ipv4"
+	if IsIPv6(remoteGateway) {
+		family = "ipv6"
+	}
+	command := fmt.Sprintf("/usr/sbin/conntrack -D -f %s -s %s -d %s -p udp", family, remoteGateway, localBalancerIP)
 	log.Printf("%s", command)
 	words := strings.Fields(command)
 	outBytes, _ := exec.Command("sudo", words...).CombinedOutput() // #nosec G104,G204 variable is built from fixed constants and network information, user can not override, ok to ignore error
@@ -101,83 +134,17 @@ func DeleteConntrackEntry(remoteGateway, localBalancerIP string) {
 	}
 }
 
-// FlushLocalSubnetNAT - Flush the settings of the local subnet NAT table
-func FlushLocalSubnetNAT() {
-	ipTablesRun("--flush -t nat")
-}
-
-// GetDeviceToWorkerNode - Get the device to route data over to get to worker node
-func GetDeviceToWorkerNode(workerNode string) string {
-	device := ""
-	outBytes, err := exec.Command("sudo", "/sbin/ip", "route", "list").CombinedOutput()
-	if err != nil {
-		log.Fatalf("ERROR: Failed to retrieve routing table: %v", err)
-	}
-	outArray := strings.Split(string(outBytes), "\n")
-	for _, line := range outArray {
-		if strings.Contains(line, workerNode) {
-			words := strings.Fields(line)
-			for i, word := range words {
-				if word == "dev" && i < len(words)-1 {
-					device = words[i+1]
-				}
-			}
-		}
+// FlushLocalSubnetNAT - Remove all of the nat rules that belong to the release.  Rules of other releases are not touched
+func FlushLocalSubnetNAT(owner string) {
+	if err := RemoveNATRules(owner); err != nil {
+		log.Printf("WARNING: %v", err)
 	}
-	// If we did not find a route to the VPN pod worker node, calico-node probably has not added it yet
-	// We can't do anything if we don't have a device name, therefore exit with a fatal error
-	if device == "" {
-		log.Print("Current routes on the node:")
-		for _, line := range outArray {
-			if len(line) > 0 {
-				log.Printf("\t%s", line)
-			}
-		}
-		log.Fatalf("ERROR: Unable to find route to VPN pod worker node: %s", workerNode)
-	}
-	return device
 }
 
-// GetRoutingTable - Retrieve the routing table and return it in an array of RoutingInfo object.
-func GetRoutingTable() []RoutingInfo {
-	var routes []RoutingInfo
-	outBytes, err := exec.Command("sudo", "/sbin/ip", "route").CombinedOutput()
-	if err != nil {
-		log.Printf("ERROR: Failed to retrieve routing table: %v", err)
-		return routes
-	}
-	log.Print("Routing Table:")
-	outArray := strings.Split(string(outBytes), "\n")
-	for _, entry := range outArray {
-		words := strings.Fields(entry)
-		if len(words) >= 3 {
-			log.Printf("\t%s", entry)
-			var route = RoutingInfo{}
-			route.Dest = words[0]
-			if route.Dest != "default" && !strings.Contains(route.Dest, "/") {
-				route.Dest += "/32"
-			}
-			for n, value := range words {
-				if value == "via" && n < len(words)-1 {
-					route.Via = words[n+1]
-				} else if value == "dev" && n < len(words)-1 {
-					route.Dev = words[n+1]
-				}
-			}
-			routes = append(routes, route)
-		}
-	}
-	return routes
-}
-
-// ipTablesRun - Run iptables command helper routine
-func ipTablesRun(command string) {
-	log.Printf("iptables-legacy %s", command)
-	ipTablesCommand := fmt.Sprintf("/usr/sbin/iptables-legacy %v", command)
-	words := strings.Fields(ipTablesCommand)
-	err := exec.Command("sudo", words...).Run() // #nosec G204 variable is built from fixed constants and network information, user can not override
-	if err != nil {
-		log.Printf("WARNING: Failed <iptables-legacy %s>: %v", command, err)
+// addNATRule - Add the nat rule using the configured firewall backend
+func addNATRule(owner string, rule NATRule) {
+	if err := AddNATRule(owner, rule); err != nil {
+		log.Printf("WARNING: %v", err)
 	}
 }
 
@@ -195,6 +162,31 @@ func IsAddrInSubnet(ipAddr, subnets string) bool {
 	return false
 }
 
+// IsIPv6 - Is the IP address or subnet an IPv6 address / subnet
+func IsIPv6(addr string) bool {
+	return strings.Contains(addr, ":")
+}
+
+// IPv6Enabled - Is IPv6 enabled in the kernel of the node.  The IPv6 routes, rules and nat rules are only read and
+// updated if it is
+func IPv6Enabled() bool {
+	_, err := os.Stat("/proc/net/if_inet6")
+	return err == nil
+}
+
+// isHostSubnet - Is the subnet a single IP address: /32 (IPv4) or /128 (IPv6)
+func isHostSubnet(subnet string) bool {
+	return strings.HasSuffix(subnet, "/32") || strings.HasSuffix(subnet, "/128")
+}
+
+// hostAddress - Remove the /32 or /128 suffix from a single IP address subnet
+func hostAddress(source string) string {
+	if isHostSubnet(source) {
+		return strings.Split(source, "/")[0]
+	}
+	return source
+}
+
 // IsAddrLocal - If the specified IP address located on this host
 func IsAddrLocal(ipAddr string) bool {
 	addrList, err := net.InterfaceAddrs()
@@ -210,38 +202,6 @@ func IsAddrLocal(ipAddr string) bool {
 	return false
 }
 
-// ListRoutes - List the current route for a specific table
-func ListRoutes(routeTable string) {
-	log.Printf("ip route list table %s", routeTable)
-	outBytes, err := exec.Command("sudo", "/sbin/ip", "route", "list", "table", routeTable).CombinedOutput() // #nosec G204 variable is built from fixed constants and network information, user can not override
-	if err != nil {
-		log.Printf("ERROR: Failed to retrieve routing table %s: %v", routeTable, err)
-		return
-	}
-	outArray := strings.Split(string(outBytes), "\n")
-	for _, line := range outArray {
-		if len(line) > 1 {
-			log.Printf("\t%s", line)
-		}
-	}
-}
-
-// ListRules - List the current ip rules
-func ListRules() {
-	log.Print("ip rules list")
-	outBytes, err := exec.Command("sudo", "/sbin/ip", "rule", "list").CombinedOutput()
-	if err != nil {
-		log.Printf("ERROR: Failed to retrieve rule list: %v", err)
-		return
-	}
-	outArray := strings.Split(string(outBytes), "\n")
-	for _, line := range outArray {
-		if strings.Contains(line, ":") {
-			log.Printf("\t%s", line)
-		}
-	}
-}
-
 // RouteRemoteSubnet - add/del routing for 1 or more remote subnets
 func RouteRemoteSubnet(addDelAction NetAddDelAction, remoteSubnet, routeInfo string) {
 	for _, subnet := range strings.Split(remoteSubnet, ",") {
@@ -254,13 +214,13 @@ func RouteRemoteSubnet(addDelAction NetAddDelAction, remoteSubnet, routeInfo str
 }
 
 // TunnelNeededToReachSubnet - Is a tunnel needed to reach this local subnet (based on routing table that is passed in)
-func TunnelNeededToReachSubnet(localSubnet string, routingTable []RoutingInfo) bool {
+func TunnelNeededToReachSubnet(localSubnet, overlayDevice string, routingTable []RoutingInfo) bool {
 	_, ipNet, err := net.ParseCIDR(localSubnet)
-	if err != nil {
+	if err != nil || overlayDevice == "" {
 		return false
 	}
 	for _, route := range routingTable {
-		if route.Dev == "tunl0" { // Found a route that requires a tunnel
+		if route.Dev == overlayDevice { // Found a route that requires a tunnel
 			if ipNet.Contains(net.ParseIP(route.Via)) { // Is the gateway for this route in the subnet that was passed in
 				return true
 			}
@@ -268,78 +228,3 @@ func TunnelNeededToReachSubnet(localSubnet string, routingTable []RoutingInfo) b
 	}
 	return false
 }
-
-// UpdateRoute - add/del routing info for a subnet
-func UpdateRoute(addDelAction NetAddDelAction, subnet, routeInfo string) {
-	routeCommand := fmt.Sprintf("/sbin/ip route %s %s %s", addDelAction, subnet, routeInfo)
-	log.Printf("%s", routeCommand)
-	words := strings.Fields(routeCommand)
-	err := exec.Command("sudo", words...).Run() // #nosec G204 variable is built from fixed constants and network information, user can not override
-	if err != nil {
-		log.Printf("WARNING: Failed <%s>: %v", routeCommand, err)
-	}
-}
-
-// UpdateRouteRule - Update the route rules (add/del) as needed depending on if they already exist
-func UpdateRouteRule(addDelAction NetAddDelAction, fromSource, routeTable string) {
-	fromSource = strings.TrimSuffix(fromSource, "/32")
-	outBytes, err := exec.Command("sudo", "/sbin/ip", "rule", "list").CombinedOutput()
-	if err != nil {
-		log.Printf("ERROR: Failed to retrieve routing rules: %v", err)
-		return
-	}
-	foundRule := false
-	outArray := strings.Split(string(outBytes), "\n")
-	for _, line := range outArray {
-		if strings.HasPrefix(line, routeTable+":") && strings.Contains(line, "from "+fromSource) {
-			foundRule = true
-		}
-	}
-	routesExist := false
-	if addDelAction == NetActionDelete {
-		outBytes, err := exec.Command("sudo", "/sbin/ip", "route", "list", "table", routeTable).CombinedOutput() // #nosec G204 variable is built from fixed constants and network information, user can not override
-		if err != nil {
-			log.Printf("ERROR: Failed to retrieve routing table %s: %v", routeTable, err)
-			return
-		}
-		outArray := strings.Split(string(outBytes), "\n")
-		for _, line := range outArray {
-			if len(line) > 1 {
-				routesExist = true
-			}
-		}
-	}
-	var ruleCommand string
-
-	// Process "add" to the rule table
-	if addDelAction == NetActionAdd {
-		// If table already exists, no need to add it
-		if foundRule {
-			log.Printf("Rule for table %s from source %s already exists", routeTable, fromSource)
-			return
-		}
-		ruleCommand = fmt.Sprintf("rule %s from %s table %s prior %s", addDelAction, fromSource, routeTable, routeTable)
-	}
-
-	// Process "del" to the rule table
-	if addDelAction == NetActionDelete {
-		// if table does not exist, no need to remove it
-		if !foundRule {
-			log.Printf("Rule for table %s from source %s does not exists", routeTable, fromSource)
-			return
-		}
-		if routesExist && fromSource == "all" {
-			log.Printf("Rule was not deleted.  Routes are still defined on table %s", routeTable)
-			return
-		}
-		ruleCommand = fmt.Sprintf("rule %s from %s table %s", addDelAction, fromSource, routeTable)
-	}
-
-	log.Printf("ip %s", ruleCommand)
-	ipRuleCommand := fmt.Sprintf("/sbin/ip %v", ruleCommand)
-	words := strings.Fields(ipRuleCommand)
-	err = exec.Command("sudo", words...).Run() // #nosec G204 variable is built from fixed constants and network information, user can not override
-	if err != nil {
-		log.Printf("WARNING: Failed <ip %s>: %v", ruleCommand, err)
-	}
-}
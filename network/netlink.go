@@ -0,0 +1,378 @@
+//go:build linux
+
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+const mainRouteTable = 254 // RT_TABLE_MAIN
+
+// NetlinkBackend - RouteBackend implementation that manipulates routes, rules and links through netlink sockets
+type NetlinkBackend struct {
+	handle *netlink.Handle
+}
+
+// NewNetlinkBackend - Create a netlink backend.  If handle is nil, a handle for the current network namespace is created
+func NewNetlinkBackend(handle *netlink.Handle) (*NetlinkBackend, error) {
+	if handle == nil {
+		var err error
+		handle, err = netlink.NewHandle(syscall.NETLINK_ROUTE)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create netlink handle: %v", err)
+		}
+	}
+	return &NetlinkBackend{handle: handle}, nil
+}
+
+// NewNetlinkBackendAt - Create a netlink backend bound to the specified network namespace
+func NewNetlinkBackendAt(ns netns.NsHandle) (*NetlinkBackend, error) {
+	handle, err := netlink.NewHandleAt(ns, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create netlink handle in namespace %s: %v", ns, err)
+	}
+	return &NetlinkBackend{handle: handle}, nil
+}
+
+// Close - Release the netlink socket
+func (nb *NetlinkBackend) Close() {
+	nb.handle.Close()
+}
+
+// GetDeviceToAddress - Get the device used by the routes in the main table that go to / through the IP address
+func (nb *NetlinkBackend) GetDeviceToAddress(ipAddr string) (string, error) {
+	ip := net.ParseIP(ipAddr)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address: %s", ipAddr)
+	}
+	routes, err := nb.handle.RouteListFiltered(addressFamily(ip), &netlink.Route{Table: mainRouteTable}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve routing table: %v", err)
+	}
+	device := ""
+	for _, route := range routes {
+		if route.Gw.Equal(ip) || (route.Dst != nil && route.Dst.IP.Equal(ip)) {
+			device = nb.linkName(route.LinkIndex)
+		}
+	}
+	return device, nil
+}
+
+// GetRoutes - Retrieve the IPv4 and IPv6 routes defined in a specific routing table
+func (nb *NetlinkBackend) GetRoutes(routeTable string) ([]RoutingInfo, error) {
+	table, err := parseTable(routeTable)
+	if err != nil {
+		return nil, err
+	}
+	result := []RoutingInfo{}
+	for _, family := range netlinkFamilies() {
+		routes, err := nb.handle.RouteListFiltered(family, &netlink.Route{Table: table}, netlink.RT_FILTER_TABLE)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve routing table %s: %v", routeTable, err)
+		}
+		for _, route := range routes {
+			result = append(result, nb.toRoutingInfo(route))
+		}
+	}
+	return result, nil
+}
+
+// GetRouteToAddress - Retrieve the route that the kernel would use to send traffic to the IP address
+func (nb *NetlinkBackend) GetRouteToAddress(ipAddr string) (RoutingInfo, error) {
+	ip := net.ParseIP(ipAddr)
+	if ip == nil {
+		return RoutingInfo{}, fmt.Errorf("invalid IP address: %s", ipAddr)
+	}
+	routes, err := nb.handle.RouteGet(ip)
+	if err != nil {
+		return RoutingInfo{}, fmt.Errorf("failed to get route to %s: %v", ipAddr, err)
+	}
+	if len(routes) == 0 {
+		return RoutingInfo{}, fmt.Errorf("no route returned for %s", ipAddr)
+	}
+	route := nb.toRoutingInfo(routes[0])
+	route.Dest = ipAddr + "/32"
+	if IsIPv6(ipAddr) {
+		route.Dest = ipAddr + "/128"
+	}
+	return route, nil
+}
+
+// GetRules - Retrieve the list of IPv4 and IPv6 ip rules
+func (nb *NetlinkBackend) GetRules() ([]RuleInfo, error) {
+	result := []RuleInfo{}
+	for _, family := range netlinkFamilies() {
+		rules, err := nb.handle.RuleList(family)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve routing rules: %v", err)
+		}
+		for _, rule := range rules {
+			from := "all"
+			if family == netlink.FAMILY_V6 {
+				from = RuleFromAllIPv6
+			}
+			if rule.Src != nil {
+				from = hostAddress(rule.Src.String())
+			}
+			result = append(result, RuleInfo{Priority: rule.Priority, From: from, Table: tableName(rule.Table)})
+		}
+	}
+	return result, nil
+}
+
+// UpdateRoute - add/del/replace routing info for a subnet.  Adding an identical route or deleting a missing route is not an error
+func (nb *NetlinkBackend) UpdateRoute(addDelAction NetAddDelAction, subnet, routeInfo string) error {
+	route, err := nb.buildRoute(subnet, routeInfo)
+	if err != nil {
+		return err
+	}
+	switch addDelAction {
+	case NetActionAdd:
+		err = nb.handle.RouteAdd(route)
+		if errors.Is(err, syscall.EEXIST) && nb.routeMatches(route) {
+			return nil
+		}
+	case NetActionReplace:
+		err = nb.handle.RouteReplace(route)
+	case NetActionDelete:
+		err = nb.handle.RouteDel(route)
+		if errors.Is(err, syscall.ESRCH) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported route action: %s", addDelAction)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to %s route %s %s: %v", addDelAction, subnet, routeInfo, err)
+	}
+	return nil
+}
+
+// UpdateRule - add/del the rule that sends traffic from the source to the routing table
+func (nb *NetlinkBackend) UpdateRule(addDelAction NetAddDelAction, fromSource, routeTable, priority string) error {
+	table, err := parseTable(routeTable)
+	if err != nil {
+		return err
+	}
+	rule := netlink.NewRule()
+	rule.Family = netlink.FAMILY_V4
+	if IsIPv6(fromSource) {
+		rule.Family = netlink.FAMILY_V6
+	}
+	rule.Table = table
+	if fromSource != "all" && fromSource != RuleFromAllIPv6 {
+		if !strings.Contains(fromSource, "/") && rule.Family == netlink.FAMILY_V6 {
+			fromSource += "/128"
+		} else if !strings.Contains(fromSource, "/") {
+			fromSource += "/32"
+		}
+		_, src, err := net.ParseCIDR(fromSource)
+		if err != nil {
+			return fmt.Errorf("invalid rule source %s: %v", fromSource, err)
+		}
+		rule.Src = src
+	}
+	switch addDelAction {
+	case NetActionAdd:
+		rule.Priority, err = strconv.Atoi(priority)
+		if err != nil {
+			return fmt.Errorf("invalid rule priority: %s", priority)
+		}
+		err = nb.handle.RuleAdd(rule)
+		if errors.Is(err, syscall.EEXIST) {
+			return nil
+		}
+	case NetActionDelete:
+		err = nb.handle.RuleDel(rule)
+		if errors.Is(err, syscall.ENOENT) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported rule action: %s", addDelAction)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to %s rule from %s table %s: %v", addDelAction, fromSource, routeTable, err)
+	}
+	return nil
+}
+
+// buildRoute - Convert the subnet and "[via <gw>] dev <device> [onlink] table <table>" route info in to a netlink route.
+// Routes with multiple next hops use "table <table> nexthop via <gw> dev <device> [onlink] weight <weight> nexthop ..."
+func (nb *NetlinkBackend) buildRoute(subnet, routeInfo string) (*netlink.Route, error) {
+	_, dst, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid route destination %s: %v", subnet, err)
+	}
+	route := &netlink.Route{Dst: dst, Table: mainRouteTable}
+	var nextHop *netlink.NexthopInfo // Set once the first "nexthop" keyword is seen
+	words := strings.Fields(routeInfo)
+	for i := 0; i < len(words); i++ {
+		switch words[i] {
+		case "onlink":
+			if nextHop != nil {
+				nextHop.Flags |= int(netlink.FLAG_ONLINK)
+			} else {
+				route.Flags |= int(netlink.FLAG_ONLINK)
+			}
+			continue
+		case "nexthop":
+			nextHop = &netlink.NexthopInfo{}
+			route.MultiPath = append(route.MultiPath, nextHop)
+			continue
+		case "via", "dev", "table", "weight":
+		default:
+			return nil, fmt.Errorf("unsupported route option %q in: %s", words[i], routeInfo)
+		}
+		if i == len(words)-1 {
+			return nil, fmt.Errorf("missing value for %q in: %s", words[i], routeInfo)
+		}
+		value := words[i+1]
+		switch words[i] {
+		case "via":
+			gateway := net.ParseIP(value)
+			if gateway == nil {
+				return nil, fmt.Errorf("invalid gateway %s in: %s", value, routeInfo)
+			}
+			if nextHop != nil {
+				nextHop.Gw = gateway
+			} else {
+				route.Gw = gateway
+			}
+		case "dev":
+			link, err := nb.handle.LinkByName(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to locate device %s: %v", value, err)
+			}
+			if nextHop != nil {
+				nextHop.LinkIndex = link.Attrs().Index
+			} else {
+				route.LinkIndex = link.Attrs().Index
+			}
+		case "table":
+			route.Table, err = parseTable(value)
+			if err != nil {
+				return nil, err
+			}
+		case "weight":
+			weight, err := strconv.Atoi(value)
+			if err != nil || weight < 1 || weight > 256 || nextHop == nil {
+				return nil, fmt.Errorf("invalid weight %s in: %s", value, routeInfo)
+			}
+			nextHop.Hops = weight - 1 // The kernel stores the weight minus one
+		}
+		i++
+	}
+	return route, nil
+}
+
+// linkName - Get the name of the device with the specified index
+func (nb *NetlinkBackend) linkName(index int) string {
+	if index == 0 {
+		return ""
+	}
+	link, err := nb.handle.LinkByIndex(index)
+	if err != nil {
+		return strconv.Itoa(index)
+	}
+	return link.Attrs().Name
+}
+
+// routeMatches - Is there an existing route with exactly the same destination, gateway, device and table
+func (nb *NetlinkBackend) routeMatches(route *netlink.Route) bool {
+	filter := &netlink.Route{Table: route.Table, Dst: route.Dst}
+	routes, err := nb.handle.RouteListFiltered(addressFamily(route.Dst.IP), filter, netlink.RT_FILTER_TABLE|netlink.RT_FILTER_DST)
+	if err != nil {
+		return false
+	}
+	for _, existing := range routes {
+		if existing.Gw.Equal(route.Gw) && existing.LinkIndex == route.LinkIndex && nextHopsMatch(existing.MultiPath, route.MultiPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextHopsMatch - Do the two multipath routes have the same next hops and weights
+func nextHopsMatch(existing, wanted []*netlink.NexthopInfo) bool {
+	if len(existing) != len(wanted) {
+		return false
+	}
+	for i := range wanted {
+		if !existing[i].Gw.Equal(wanted[i].Gw) || existing[i].LinkIndex != wanted[i].LinkIndex || existing[i].Hops != wanted[i].Hops {
+			return false
+		}
+	}
+	return true
+}
+
+// toRoutingInfo - Convert a netlink route in to a RoutingInfo object.  The IPv6 default route is "::/0"
+func (nb *NetlinkBackend) toRoutingInfo(route netlink.Route) RoutingInfo {
+	info := RoutingInfo{Dest: "default", Dev: nb.linkName(route.LinkIndex)}
+	if route.Family == netlink.FAMILY_V6 {
+		info.Dest = RuleFromAllIPv6
+	}
+	if route.Dst != nil {
+		info.Dest = route.Dst.String()
+	}
+	if route.Gw != nil {
+		info.Via = route.Gw.String()
+	}
+	if route.Table != mainRouteTable && route.Table != 0 {
+		info.Table = tableName(route.Table)
+	}
+	return info
+}
+
+// addressFamily - netlink address family of the IP address
+func addressFamily(ip net.IP) int {
+	if ip.To4() == nil {
+		return netlink.FAMILY_V6
+	}
+	return netlink.FAMILY_V4
+}
+
+// netlinkFamilies - Address families used on the node.  IPv6 is skipped if it is disabled
+func netlinkFamilies() []int {
+	if IPv6Enabled() {
+		return []int{netlink.FAMILY_V4, netlink.FAMILY_V6}
+	}
+	return []int{netlink.FAMILY_V4}
+}
+
+// parseTable - Convert the routing table name / number to the table number
+func parseTable(routeTable string) (int, error) {
+	if routeTable == "main" {
+		return mainRouteTable, nil
+	}
+	table, err := strconv.Atoi(routeTable)
+	if err != nil || table <= 0 {
+		return 0, fmt.Errorf("invalid routing table: %s", routeTable)
+	}
+	return table, nil
+}
+
+// tableName - Convert the routing table number to the name displayed by "ip"
+func tableName(table int) string {
+	if table == mainRouteTable {
+		return "main"
+	}
+	return strconv.Itoa(table)
+}
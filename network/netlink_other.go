@@ -0,0 +1,29 @@
+//go:build !linux
+
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package network
+
+import (
+	"errors"
+
+	"github.com/vishvananda/netlink"
+)
+
+// NetlinkBackend - netlink is only available on Linux
+type NetlinkBackend struct {
+	ipCommandBackend
+}
+
+// NewNetlinkBackend - netlink is only available on Linux
+func NewNetlinkBackend(_ *netlink.Handle) (*NetlinkBackend, error) {
+	return nil, errors.New("the netlink route backend is only supported on Linux")
+}
@@ -0,0 +1,178 @@
+//go:build linux
+
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package network
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+const envVarNetlinkTest = "NETLINK_TEST_NAMESPACE" // Set when the test is running in its own user and network namespace
+
+// The backend is tested in a new user and network namespace, so no privileges are needed.  A multithreaded process
+// can not unshare its user namespace, so the test binary runs the test again in the new namespaces
+func TestNetlinkBackend(t *testing.T) {
+	if os.Getenv(envVarNetlinkTest) == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestNetlinkBackend$", "-test.v") // #nosec G204 runs the test binary itself
+		cmd.Env = append(os.Environ(), envVarNetlinkTest+"=1")
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET,
+			UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+			GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		}
+		output, err := cmd.CombinedOutput()
+		if _, ok := err.(*exec.ExitError); !ok && err != nil {
+			t.Skipf("unshare(CLONE_NEWUSER|CLONE_NEWNET) is not permitted: %v", err)
+		}
+		t.Logf("%s", output)
+		if err != nil {
+			t.Fatalf("test in the network namespace failed: %v", err)
+		}
+		if strings.Contains(string(output), "--- SKIP") {
+			t.Skip("skipped in the network namespace")
+		}
+		return
+	}
+
+	ns, err := netns.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ns.Close()
+	nb, err := NewNetlinkBackendAt(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nb.Close()
+	setupTestLink(t, nb.handle)
+
+	// Routes
+	mustUpdateRoute(t, nb, NetActionAdd, "192.168.50.0/24", "via 10.99.0.2 dev strongswan0 table 201")
+	mustUpdateRoute(t, nb, NetActionAdd, "192.168.50.0/24", "via 10.99.0.2 dev strongswan0 table 201") // identical route
+	checkRoutes(t, nb, "201", []RoutingInfo{{Dest: "192.168.50.0/24", Via: "10.99.0.2", Dev: "strongswan0", Table: "201"}})
+	if err := nb.UpdateRoute(NetActionAdd, "192.168.50.0/24", "via 10.99.0.3 dev strongswan0 table 201"); err == nil {
+		t.Error("adding a different route for the same subnet did not fail")
+	}
+	mustUpdateRoute(t, nb, NetActionReplace, "192.168.50.0/24", "via 10.99.0.3 dev strongswan0 table 201")
+	mustUpdateRoute(t, nb, NetActionReplace, "192.168.60.0/24", "via 10.99.0.4 dev strongswan0 onlink table 201")
+	checkRoutes(t, nb, "201", []RoutingInfo{
+		{Dest: "192.168.50.0/24", Via: "10.99.0.3", Dev: "strongswan0", Table: "201"},
+		{Dest: "192.168.60.0/24", Via: "10.99.0.4", Dev: "strongswan0", Table: "201"},
+	})
+	mustUpdateRoute(t, nb, NetActionDelete, "192.168.50.0/24", "via 10.99.0.3 dev strongswan0 table 201")
+	mustUpdateRoute(t, nb, NetActionDelete, "192.168.50.0/24", "via 10.99.0.3 dev strongswan0 table 201") // missing route
+	checkRoutes(t, nb, "201", []RoutingInfo{{Dest: "192.168.60.0/24", Via: "10.99.0.4", Dev: "strongswan0", Table: "201"}})
+	if err := nb.UpdateRoute(NetActionAdd, "192.168.70.0/24", "via 10.99.0.2 dev missing0 table 201"); err == nil {
+		t.Error("adding a route through a missing device did not fail")
+	}
+	device, err := nb.GetDeviceToAddress("10.99.0.4")
+	if err != nil || device != "" {
+		t.Errorf("GetDeviceToAddress(10.99.0.4) = %q, %v; want no device, the route is not in the main table", device, err)
+	}
+	mustUpdateRoute(t, nb, NetActionAdd, "10.98.0.0/24", "via 10.99.0.5 dev strongswan0")
+	if device, err := nb.GetDeviceToAddress("10.99.0.5"); err != nil || device != "strongswan0" {
+		t.Errorf("GetDeviceToAddress(10.99.0.5) = %q, %v; want strongswan0", device, err)
+	}
+
+	// Rules
+	mustUpdateRule(t, nb, NetActionAdd, "all", "201", "201")
+	mustUpdateRule(t, nb, NetActionAdd, "all", "201", "201") // existing rule
+	mustUpdateRule(t, nb, NetActionAdd, "172.30.5.6", "201", "150")
+	checkRule(t, nb, RuleInfo{Priority: 201, From: "all", Table: "201"}, true)
+	checkRule(t, nb, RuleInfo{Priority: 150, From: "172.30.5.6", Table: "201"}, true)
+	mustUpdateRule(t, nb, NetActionDelete, "172.30.5.6", "201", "")
+	mustUpdateRule(t, nb, NetActionDelete, "172.30.5.6", "201", "") // missing rule
+	checkRule(t, nb, RuleInfo{Priority: 150, From: "172.30.5.6", Table: "201"}, false)
+	mustUpdateRule(t, nb, NetActionDelete, "all", "201", "")
+	checkRule(t, nb, RuleInfo{Priority: 201, From: "all", Table: "201"}, false)
+}
+
+// Create a veth pair in the namespace for the routes to use.  The dummy link type is not available on all kernels
+func setupTestLink(t *testing.T, handle *netlink.Handle) {
+	link := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "strongswan0"}, PeerName: "strongswan1"}
+	if err := handle.LinkAdd(link); errors.Is(err, syscall.EOPNOTSUPP) {
+		t.Skipf("veth links are not supported: %v", err)
+	} else if err != nil {
+		t.Fatalf("failed to add link: %v", err)
+	}
+	address, _ := netlink.ParseAddr("10.99.0.1/24") // #nosec G104 constant address
+	if err := handle.AddrAdd(link, address); err != nil {
+		t.Fatalf("failed to add address: %v", err)
+	}
+	if err := handle.LinkSetUp(link); err != nil {
+		t.Fatalf("failed to set link up: %v", err)
+	}
+}
+
+func mustUpdateRoute(t *testing.T, nb *NetlinkBackend, action NetAddDelAction, subnet, routeInfo string) {
+	t.Helper()
+	if err := nb.UpdateRoute(action, subnet, routeInfo); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustUpdateRule(t *testing.T, nb *NetlinkBackend, action NetAddDelAction, fromSource, routeTable, priority string) {
+	t.Helper()
+	if err := nb.UpdateRule(action, fromSource, routeTable, priority); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Compare the IPv4 routes of the table with the expected routes
+func checkRoutes(t *testing.T, nb *NetlinkBackend, routeTable string, want []RoutingInfo) {
+	t.Helper()
+	routes, err := nb.GetRoutes(routeTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []RoutingInfo{}
+	for _, route := range routes {
+		if _, dst, err := net.ParseCIDR(route.Dest); err == nil && dst.IP.To4() != nil {
+			got = append(got, route)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("table %s routes = %v, want %v", routeTable, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("table %s routes = %v, want %v", routeTable, got, want)
+		}
+	}
+}
+
+// Check whether the rule is listed
+func checkRule(t *testing.T, nb *NetlinkBackend, rule RuleInfo, want bool) {
+	t.Helper()
+	rules, err := nb.GetRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, existing := range rules {
+		if existing == rule {
+			found = true
+		}
+	}
+	if found != want {
+		t.Errorf("rule %+v listed = %v, want %v: %+v", rule, found, want, rules)
+	}
+}
@@ -0,0 +1,145 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package network
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+const (
+	nftCommand = "/usr/sbin/nft"
+	nftTable   = "strongswan" // All of the VPN nat rules are placed in the "ip strongswan" and "ip6 strongswan" tables
+)
+
+// nftablesFirewall - Firewall implementation that uses native nftables rules in a dedicated table
+type nftablesFirewall struct{}
+
+// Name - Name of the firewall backend
+func (fw *nftablesFirewall) Name() string {
+	return FirewallNftables
+}
+
+// ApplyNATRules - Replace the rules of the owner in the chain of the strongswan tables with a single nft transaction.
+// Rules that belong to other releases are preserved.  The IPv6 rules are only applied if IPv6 is enabled on the node
+func (fw *nftablesFirewall) ApplyNATRules(chain, owner string, rules []NATRule) error {
+	families := []string{"ip"}
+	if IPv6Enabled() {
+		families = append(families, "ip6")
+	}
+	script := ""
+	for _, family := range families {
+		handles, err := nftRuleHandles(family, chain, ownerComment(owner)+":")
+		if err != nil {
+			return err
+		}
+		script += nftTableDefinition(family)
+		for _, handle := range handles {
+			script += fmt.Sprintf("delete rule %s %s %s handle %s\n", family, nftTable, nftChain(chain), handle)
+		}
+		for _, rule := range natRulesOfFamily(rules, family == "ip6") {
+			script += fmt.Sprintf("add rule %s %s %s %s\n", family, nftTable, nftChain(chain), rule.nftExpression(rule.comment(owner)))
+		}
+	}
+	return nftRunScript(script)
+}
+
+// NATRuleExists - Is the rule defined in the strongswan table
+func (fw *nftablesFirewall) NATRuleExists(owner string, rule NATRule) (bool, error) {
+	handles, err := nftRuleHandles(rule.nftFamily(), rule.Chain, rule.comment(owner))
+	return len(handles) > 0, err
+}
+
+// nftChain - Name of the nftables chain used for the iptables built-in chain
+func nftChain(chain string) string {
+	return strings.ToLower(chain)
+}
+
+// nftTableDefinition - Script used to create the strongswan table of the family ("ip" or "ip6") and its nat chains
+// if they do not exist
+func nftTableDefinition(family string) string {
+	script := fmt.Sprintf("add table %s %s\n", family, nftTable)
+	script += fmt.Sprintf("add chain %s %s %s { type nat hook postrouting priority 100 ; }\n", family, nftTable, nftChain(NATChainPostrouting))
+	script += fmt.Sprintf("add chain %s %s %s { type nat hook prerouting priority -100 ; }\n", family, nftTable, nftChain(NATChainPrerouting))
+	return script
+}
+
+// nftRuleHandles - Locate the handles of the rules in the table of the family whose comment starts with the prefix
+func nftRuleHandles(family, chain, commentPrefix string) ([]string, error) {
+	outBytes, err := exec.Command("sudo", nftCommand, "-a", "list", "chain", family, nftTable, nftChain(chain)).CombinedOutput() // #nosec G204 variable is built from fixed constants and network information, user can not override
+	if err != nil {
+		// The table / chain does not exist yet, so there are no rules
+		if strings.Contains(string(outBytes), "No such file or directory") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list nftables chain %s: %v - %s", nftChain(chain), err, strings.TrimSpace(string(outBytes)))
+	}
+	handles := []string{}
+	for _, line := range strings.Split(string(outBytes), "\n") {
+		if strings.Contains(line, `comment "`+commentPrefix) {
+			if index := strings.LastIndex(line, "# handle "); index >= 0 {
+				handles = append(handles, strings.TrimSpace(line[index+len("# handle "):]))
+			}
+		}
+	}
+	return handles, nil
+}
+
+// nftRunScript - Run a set of nft commands as a single transaction
+func nftRunScript(script string) error {
+	log.Printf("nft -f -\n%s", script)
+	cmd := exec.Command("sudo", nftCommand, "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+	outBytes, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed <nft -f>: %v - %s", err, strings.TrimSpace(string(outBytes)))
+	}
+	return nil
+}
+
+// nftFamily - nftables family of the table that holds the rule
+func (rule NATRule) nftFamily() string {
+	if rule.ipv6() {
+		return "ip6"
+	}
+	return "ip"
+}
+
+// nftExpression - Build the nftables match / statement expression for the rule, tagged with the comment
+func (rule NATRule) nftExpression(comment string) string {
+	family := rule.nftFamily()
+	expr := ""
+	if rule.Source != "" {
+		expr += family + " saddr " + rule.Source + " "
+	}
+	if rule.Dest != "" {
+		expr += family + " daddr " + rule.Dest + " "
+	}
+	if rule.Protocol != "" {
+		expr += "meta l4proto " + rule.Protocol + " "
+	}
+	switch rule.Target {
+	case NATTargetMasquerade:
+		expr += "masquerade"
+	case NATTargetSNAT:
+		expr += "snat to " + rule.To
+	case NATTargetNetmap:
+		// NETMAP keeps the host part of the address and replaces the network part
+		if rule.Chain == NATChainPrerouting {
+			expr += fmt.Sprintf("dnat %s prefix to %s daddr map { %s : %s }", family, family, rule.Dest, rule.To)
+		} else {
+			expr += fmt.Sprintf("snat %s prefix to %s saddr map { %s : %s }", family, family, rule.Source, rule.To)
+		}
+	}
+	return expr + fmt.Sprintf(" comment %q", comment)
+}
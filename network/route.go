@@ -0,0 +1,241 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package network
+
+import (
+	"fmt"
+	"log"
+)
+
+// RouteBackend - Implementation used to read and update the routes and rules on the node
+type RouteBackend interface {
+	GetDeviceToAddress(ipAddr string) (string, error)
+	GetRoutes(routeTable string) ([]RoutingInfo, error)
+	GetRouteToAddress(ipAddr string) (RoutingInfo, error)
+	GetRules() ([]RuleInfo, error)
+	UpdateRoute(addDelAction NetAddDelAction, subnet, routeInfo string) error
+	UpdateRule(addDelAction NetAddDelAction, fromSource, routeTable, priority string) error
+}
+
+// Supported route backends
+const (
+	RouteBackendIP      = "ip"      // Run the "ip" command through sudo
+	RouteBackendNetlink = "netlink" // Use netlink sockets directly (requires CAP_NET_ADMIN in the process)
+)
+
+// RuleInfo - priority, source and routing table of an ip rule
+type RuleInfo struct {
+	Priority int
+	From     string // "all", RuleFromAllIPv6 or the source IP / subnet (/32 and /128 suffix removed)
+	Table    string
+}
+
+var routeBackend RouteBackend = ipCommandBackend{}
+
+// SetRouteBackend - Select the implementation used for route and rule changes
+func SetRouteBackend(name string) error {
+	if name == "" {
+		name = RouteBackendIP
+	}
+	switch name {
+	case RouteBackendIP:
+		routeBackend = ipCommandBackend{}
+	case RouteBackendNetlink:
+		backend, err := NewNetlinkBackend(nil)
+		if err != nil {
+			return err
+		}
+		routeBackend = backend
+	default:
+		return fmt.Errorf("unknown route backend: %s  Valid choices: [ %s, %s ]", name, RouteBackendIP, RouteBackendNetlink)
+	}
+	log.Printf("Using route backend: %s", name)
+	return nil
+}
+
+// UseRouteBackend - Use the specified RouteBackend implementation.  Allows a backend bound to a different network namespace
+func UseRouteBackend(backend RouteBackend) {
+	routeBackend = backend
+}
+
+// String - Display the route in a format similar to "ip route"
+func (route RoutingInfo) String() string {
+	buffer := route.Dest
+	if route.Via != "" {
+		buffer += " via " + route.Via
+	}
+	if route.Dev != "" {
+		buffer += " dev " + route.Dev
+	}
+	if route.Table != "" {
+		buffer += " table " + route.Table
+	}
+	return buffer
+}
+
+// String - Display the rule in a format similar to "ip rule"
+func (rule RuleInfo) String() string {
+	return fmt.Sprintf("%d:\tfrom %s lookup %s", rule.Priority, rule.From, rule.Table)
+}
+
+// GetDeviceToWorkerNode - Get the device to route data over to get to worker node
+func GetDeviceToWorkerNode(workerNode string) (string, error) {
+	device, err := routeBackend.GetDeviceToAddress(workerNode)
+	if err != nil {
+		return "", err
+	}
+	// If we did not find a route to the VPN pod worker node, calico-node probably has not added it yet
+	if device == "" {
+		log.Print("Current routes on the node:")
+		routes, _ := routeBackend.GetRoutes("main") // #nosec G104 only used for logging
+		for _, route := range routes {
+			log.Printf("\t%s", route)
+		}
+		return "", fmt.Errorf("unable to find route to VPN pod worker node: %s", workerNode)
+	}
+	return device, nil
+}
+
+// GetRoutingTable - Retrieve the routing table and return it in an array of RoutingInfo object.
+func GetRoutingTable() []RoutingInfo {
+	routes, err := routeBackend.GetRoutes("main")
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		return routes
+	}
+	log.Print("Routing Table:")
+	for _, route := range routes {
+		log.Printf("\t%s", route)
+	}
+	return routes
+}
+
+// GetRoutes - Retrieve the routes defined in a specific routing table
+func GetRoutes(routeTable string) ([]RoutingInfo, error) {
+	return routeBackend.GetRoutes(routeTable)
+}
+
+// GetRouteToAddress - Retrieve the route that the kernel would use to send traffic to the IP address
+func GetRouteToAddress(ipAddr string) (RoutingInfo, error) {
+	return routeBackend.GetRouteToAddress(ipAddr)
+}
+
+// GetRules - Retrieve the list of ip rules
+func GetRules() ([]RuleInfo, error) {
+	return routeBackend.GetRules()
+}
+
+// ListRoutes - List the current route for a specific table
+func ListRoutes(routeTable string) {
+	log.Printf("ip route list table %s", routeTable)
+	routes, err := routeBackend.GetRoutes(routeTable)
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		return
+	}
+	for _, route := range routes {
+		log.Printf("\t%s", route)
+	}
+}
+
+// ListRules - List the current ip rules
+func ListRules() {
+	log.Print("ip rules list")
+	rules, err := routeBackend.GetRules()
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		return
+	}
+	for _, rule := range rules {
+		log.Printf("\t%s", rule)
+	}
+}
+
+// RuleExists - Is there a rule directing traffic from the source to the specified routing table
+func RuleExists(fromSource, routeTable string) (bool, error) {
+	fromSource = hostAddress(fromSource)
+	rules, err := routeBackend.GetRules()
+	if err != nil {
+		return false, err
+	}
+	for _, rule := range rules {
+		if rule.Table == routeTable && rule.From == fromSource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// UpdateRoute - add/del routing info for a subnet
+func UpdateRoute(addDelAction NetAddDelAction, subnet, routeInfo string) {
+	log.Printf("/sbin/ip %sroute %s %s %s", familyOption(subnet), addDelAction, subnet, routeInfo)
+	if err := routeBackend.UpdateRoute(addDelAction, subnet, routeInfo); err != nil {
+		log.Printf("WARNING: %v", err)
+	}
+}
+
+// UpdateRouteRule - Update the route rules (add/del) as needed depending on if they already exist.
+// If the priority is not specified, the routing table number is used as the rule priority
+func UpdateRouteRule(addDelAction NetAddDelAction, fromSource, routeTable, priority string) {
+	if priority == "" {
+		priority = routeTable
+	}
+	fromSource = hostAddress(fromSource)
+	foundRule, err := RuleExists(fromSource, routeTable)
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		return
+	}
+
+	// Process "add" to the rule table
+	if addDelAction == NetActionAdd {
+		// If table already exists, no need to add it
+		if foundRule {
+			log.Printf("Rule for table %s from source %s already exists", routeTable, fromSource)
+			return
+		}
+	}
+
+	// Process "del" to the rule table
+	if addDelAction == NetActionDelete {
+		// if table does not exist, no need to remove it
+		if !foundRule {
+			log.Printf("Rule for table %s from source %s does not exists", routeTable, fromSource)
+			return
+		}
+		if fromSource == "all" || fromSource == RuleFromAllIPv6 {
+			routes, err := routeBackend.GetRoutes(routeTable)
+			if err != nil {
+				log.Printf("ERROR: %v", err)
+				return
+			}
+			for _, route := range routes {
+				if IsIPv6(route.Dest) == (fromSource == RuleFromAllIPv6) {
+					log.Printf("Rule was not deleted.  Routes are still defined on table %s", routeTable)
+					return
+				}
+			}
+		}
+	}
+
+	log.Printf("ip %srule %s from %s table %s", familyOption(fromSource), addDelAction, fromSource, routeTable)
+	if err := routeBackend.UpdateRule(addDelAction, fromSource, routeTable, priority); err != nil {
+		log.Printf("WARNING: %v", err)
+	}
+}
+
+// familyOption - "-6 " option of the ip command for IPv6 subnets, empty for IPv4
+func familyOption(subnet string) string {
+	if IsIPv6(subnet) {
+		return "-6 "
+	}
+	return ""
+}
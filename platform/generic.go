@@ -0,0 +1,76 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package platform
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/IBM-Cloud/iks-strongswan/kube"
+)
+
+// kubeadm stores the cluster configuration in a config map
+const (
+	kubeadmNamespace = "kube-system"
+	kubeadmConfigMap = "kubeadm-config"
+	kubeadmConfigKey = "ClusterConfiguration"
+)
+
+// genericProvider - Any Kubernetes cluster, using only the standard labels and resources
+type genericProvider struct{}
+
+func (p genericProvider) Name() string {
+	return PlatformGeneric
+}
+
+// The UID of the kube-system namespace does not change for the life of the cluster
+func (p genericProvider) ClusterID(client *kubernetes.Clientset) string {
+	uid, err := kube.GetNamespaceUID(client, "kube-system")
+	if err != nil {
+		return ""
+	}
+	return uid
+}
+
+func (p genericProvider) NodeZone(client *kubernetes.Clientset, nodeName, nodeIP string) (string, error) {
+	return kube.GetNodeTopologyZone(client, nodeName, nodeIP)
+}
+
+func (p genericProvider) NodePublicIP(client *kubernetes.Clientset, nodeName, nodeIP string, sources []kube.NodeIPSource) (string, error) {
+	return kube.GetNodePublicIP(client, nodeName, nodeIP, sources)
+}
+
+// Load balancer IPs are not assumed to be bound on the worker nodes
+func (p genericProvider) LoadBalancerSourceIP() bool {
+	return false
+}
+
+// Clusters created with kubeadm have the pod and service subnets in the kubeadm configuration
+func (p genericProvider) ClusterSubnets(client *kubernetes.Clientset) []string {
+	data, err := kube.GetConfigMapData(client, kubeadmNamespace, kubeadmConfigMap)
+	if err != nil {
+		return nil
+	}
+	config := struct {
+		Networking struct {
+			PodSubnet     string `yaml:"podSubnet"`
+			ServiceSubnet string `yaml:"serviceSubnet"`
+		} `yaml:"networking"`
+	}{}
+	if err := yaml.Unmarshal([]byte(data[kubeadmConfigKey]), &config); err != nil {
+		return nil
+	}
+	subnets := strings.Split(config.Networking.PodSubnet, ",")
+	subnets = append(subnets, strings.Split(config.Networking.ServiceSubnet, ",")...)
+	return validSubnets(subnets)
+}
@@ -0,0 +1,70 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package platform
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/IBM-Cloud/iks-strongswan/kube"
+)
+
+// IBM Cloud worker node labels
+const (
+	ibmExternalIPLabel = "ibm-cloud.kubernetes.io/external-ip"
+	ibmProviderLabel   = "ibm-cloud.kubernetes.io/iaas-provider"
+	ibmProviderClassic = "softlayer"
+	ibmZoneLabel       = "ibm-cloud.kubernetes.io/zone"
+)
+
+// ibmProvider - IBM Cloud Kubernetes Service and Red Hat OpenShift on IBM Cloud
+type ibmProvider struct {
+	vpc bool // VPC infrastructure instead of classic
+}
+
+func (p ibmProvider) Name() string {
+	if p.vpc {
+		return PlatformIBMVPC
+	}
+	return PlatformIBMClassic
+}
+
+// The cluster ID is stored in the kube-system/cluster-info config map
+func (p ibmProvider) ClusterID(client *kubernetes.Clientset) string {
+	return kube.GetClusterID(client)
+}
+
+// The IBM Cloud zone label is set on all of the worker nodes
+func (p ibmProvider) NodeZone(client *kubernetes.Clientset, nodeName, nodeIP string) (string, error) {
+	return kube.GetNodeZone(client, nodeName, nodeIP)
+}
+
+// Classic worker nodes on a public VLAN have an external IP.  VPC worker nodes do not have a public IP
+func (p ibmProvider) NodePublicIP(client *kubernetes.Clientset, nodeName, nodeIP string, sources []kube.NodeIPSource) (string, error) {
+	if len(sources) == 0 {
+		sources = []kube.NodeIPSource{
+			{Kind: kube.NodeIPSourceAddress, Key: string(corev1.NodeExternalIP)},
+			{Kind: kube.NodeIPSourceLabel, Key: ibmExternalIPLabel},
+		}
+	}
+	return kube.GetNodePublicIP(client, nodeName, nodeIP, sources)
+}
+
+// Classic load balancers use a portable public IP that is bound on the worker nodes of the VLAN.  VPC load
+// balancers are outside of the cluster and are only reached through their host name
+func (p ibmProvider) LoadBalancerSourceIP() bool {
+	return !p.vpc
+}
+
+// The pod and service subnets are chosen when the cluster is created and are not published in the cluster
+func (p ibmProvider) ClusterSubnets(client *kubernetes.Clientset) []string {
+	return nil
+}
@@ -0,0 +1,93 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package platform
+
+import (
+	"encoding/json"
+	"log"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/IBM-Cloud/iks-strongswan/kube"
+)
+
+// OpenShift cluster configuration resources
+const (
+	openshiftConfigGroup    = "config.openshift.io/v1"
+	openshiftClusterVersion = "/apis/config.openshift.io/v1/clusterversions/version"
+	openshiftNetwork        = "/apis/config.openshift.io/v1/networks/cluster"
+)
+
+// openshiftProvider - Red Hat OpenShift Container Platform
+type openshiftProvider struct{}
+
+func (p openshiftProvider) Name() string {
+	return PlatformOpenShift
+}
+
+// The cluster ID is part of the cluster version resource
+func (p openshiftProvider) ClusterID(client *kubernetes.Clientset) string {
+	data, err := getResource(client, openshiftClusterVersion)
+	if err != nil {
+		log.Printf("WARNING: Unable to get the OpenShift cluster version: %v", err)
+		return ""
+	}
+	version := struct {
+		Spec struct {
+			ClusterID string `json:"clusterID"`
+		} `json:"spec"`
+	}{}
+	if err := json.Unmarshal(data, &version); err != nil {
+		log.Printf("WARNING: Unable to parse the OpenShift cluster version: %v", err)
+		return ""
+	}
+	return version.Spec.ClusterID
+}
+
+// Worker nodes have the standard topology labels
+func (p openshiftProvider) NodeZone(client *kubernetes.Clientset, nodeName, nodeIP string) (string, error) {
+	return kube.GetNodeTopologyZone(client, nodeName, nodeIP)
+}
+
+func (p openshiftProvider) NodePublicIP(client *kubernetes.Clientset, nodeName, nodeIP string, sources []kube.NodeIPSource) (string, error) {
+	return kube.GetNodePublicIP(client, nodeName, nodeIP, sources)
+}
+
+// Load balancers are provided by the cloud the cluster is installed on and are outside of the worker nodes
+func (p openshiftProvider) LoadBalancerSourceIP() bool {
+	return false
+}
+
+// The pod and service subnets are in the status of the cluster network configuration
+func (p openshiftProvider) ClusterSubnets(client *kubernetes.Clientset) []string {
+	data, err := getResource(client, openshiftNetwork)
+	if err != nil {
+		log.Printf("WARNING: Unable to get the OpenShift network configuration: %v", err)
+		return nil
+	}
+	network := struct {
+		Status struct {
+			ClusterNetwork []struct {
+				CIDR string `json:"cidr"`
+			} `json:"clusterNetwork"`
+			ServiceNetwork []string `json:"serviceNetwork"`
+		} `json:"status"`
+	}{}
+	if err := json.Unmarshal(data, &network); err != nil {
+		log.Printf("WARNING: Unable to parse the OpenShift network configuration: %v", err)
+		return nil
+	}
+	subnets := network.Status.ServiceNetwork
+	for _, clusterNetwork := range network.Status.ClusterNetwork {
+		subnets = append(subnets, clusterNetwork.CIDR)
+	}
+	return validSubnets(subnets)
+}
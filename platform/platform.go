@@ -0,0 +1,117 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+// Package platform provides GO methods for the cluster metadata that depends on where the cluster runs
+package platform
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/IBM-Cloud/iks-strongswan/kube"
+)
+
+// Supported platforms
+const (
+	PlatformAuto       = "auto"        // Detect the platform from the cluster
+	PlatformIBMClassic = "ibm-classic" // IBM Cloud Kubernetes Service / Red Hat OpenShift on IBM Cloud, classic infrastructure
+	PlatformIBMVPC     = "ibm-vpc"     // IBM Cloud Kubernetes Service / Red Hat OpenShift on IBM Cloud, VPC infrastructure
+	PlatformOpenShift  = "openshift"   // Red Hat OpenShift Container Platform
+	PlatformGeneric    = "generic"     // Any other Kubernetes cluster
+)
+
+// Provider - Cluster metadata that is specific to the platform the cluster runs on
+type Provider interface {
+	// Name of the platform
+	Name() string
+	// ClusterID - Identifier of the cluster, shown in the monitoring messages.  Empty if it can not be determined
+	ClusterID(client *kubernetes.Clientset) string
+	// NodeZone - Zone of the worker node
+	NodeZone(client *kubernetes.Clientset, nodeName, nodeIP string) (string, error)
+	// NodePublicIP - Public IP of the worker node, read from the sources if specified or the platform defaults
+	NodePublicIP(client *kubernetes.Clientset, nodeName, nodeIP string, sources []kube.NodeIPSource) (string, error)
+	// LoadBalancerSourceIP - Can the load balancer IP be used as the source IP of the outbound VPN connection.  Only
+	// possible if the load balancer IP is bound on the worker nodes
+	LoadBalancerSourceIP() bool
+	// ClusterSubnets - Pod and service subnets of the cluster.  Empty if they can not be determined
+	ClusterSubnets(client *kubernetes.Clientset) []string
+}
+
+var provider Provider = genericProvider{}
+
+// Init - Select the platform provider.  With PlatformAuto, the platform is detected from the labels of the worker
+// node and the API groups served by the cluster
+func Init(client *kubernetes.Clientset, name, nodeName, nodeIP string) error {
+	switch name {
+	case "", PlatformAuto:
+		provider = detect(client, nodeName, nodeIP)
+	case PlatformIBMClassic:
+		provider = ibmProvider{vpc: false}
+	case PlatformIBMVPC:
+		provider = ibmProvider{vpc: true}
+	case PlatformOpenShift:
+		provider = openshiftProvider{}
+	case PlatformGeneric:
+		provider = genericProvider{}
+	default:
+		return fmt.Errorf("unknown platform: %s", name)
+	}
+	return nil
+}
+
+// Get - Platform provider selected by Init
+func Get() Provider {
+	return provider
+}
+
+// detect - Determine the platform.  IBM Cloud worker nodes are labeled with their infrastructure provider, this is
+// checked first since Red Hat OpenShift on IBM Cloud also serves the OpenShift API groups
+func detect(client *kubernetes.Clientset, nodeName, nodeIP string) Provider {
+	if client == nil {
+		return genericProvider{}
+	}
+	node, err := kube.GetNode(client, nodeName, nodeIP)
+	if err != nil {
+		log.Printf("WARNING: Unable to detect the platform, using %s: %v", PlatformGeneric, err)
+		return genericProvider{}
+	}
+	if iaas, ok := node.Labels[ibmProviderLabel]; ok {
+		return ibmProvider{vpc: iaas != ibmProviderClassic}
+	}
+	if _, ok := node.Labels[ibmZoneLabel]; ok {
+		return ibmProvider{vpc: false}
+	}
+	if _, err := client.Discovery().ServerResourcesForGroupVersion(openshiftConfigGroup); err == nil {
+		return openshiftProvider{}
+	}
+	return genericProvider{}
+}
+
+// getResource - Retrieve a resource that is not part of the kubernetes client set (ex: OpenShift config)
+func getResource(client *kubernetes.Clientset, path string) ([]byte, error) {
+	return client.Discovery().RESTClient().Get().AbsPath(path).DoRaw(context.TODO())
+}
+
+// validSubnets - Filter out the subnets that are not valid CIDRs
+func validSubnets(subnets []string) []string {
+	valid := []string{}
+	for _, subnet := range subnets {
+		subnet = strings.TrimSpace(subnet)
+		if _, _, err := net.ParseCIDR(subnet); err == nil {
+			valid = append(valid, subnet)
+		}
+	}
+	return valid
+}
@@ -0,0 +1,123 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/IBM-Cloud/iks-strongswan/kube"
+)
+
+// Various constants
+const (
+	defaultRouteDaemonTimeout = 120 // seconds
+	routeDaemonAttempts       = 3
+	routeDaemonPollInterval   = 2 * time.Second
+)
+
+var routeDaemonTimeout time.Duration // How long the VPN pod waits for the route daemon to acknowledge the SNAT rules
+
+// Determine how long the VPN pod waits for each attempt of the route daemon handshake
+func initRouteDaemonTimeout() {
+	routeDaemonTimeout = time.Duration(defaultRouteDaemonTimeout) * time.Second
+	timeout := os.Getenv(envVarRouteDaemonWait)
+	if timeout == "" {
+		return
+	}
+	seconds, err := strconv.Atoi(timeout)
+	if err != nil || seconds <= 0 {
+		log.Fatalf("ERROR: Invalid value specified for %s: %s", envVarRouteDaemonWait, timeout)
+	}
+	routeDaemonTimeout = time.Duration(seconds) * time.Second
+}
+
+// Wait for the route daemon on this worker node to acknowledge the SNAT rules, then report the state of the other nodes
+func vpnPodWaitRouteDaemon(kubectl *kubernetes.Clientset) {
+	if disableRouting {
+		return
+	}
+	if connectUsingVip {
+		waitForRouteDaemon(kubectl)
+	}
+
+	// Publish the state of the tunnel for the route daemons of its tunnel group
+	startTunnelStateUpdates(kubectl)
+
+	// Apply changes of the load balancer IP
+	startLoadBalancerWatch(kubectl)
+
+	// Report which worker nodes have applied the routes
+	go routeStatusThread(kubectl)
+}
+
+// Wait until the route daemon on this worker node has applied the current generation of the routes config map.
+// If an attempt times out, the config map is written again (new generation) so the route daemon re-applies the
+// routes.  VPN pod start up fails if none of the attempts succeed.  Also called after start up, when
+// connectUsingLoadBalancerIP is enabled once the load balancer IP is assigned
+func waitForRouteDaemon(kubectl *kubernetes.Clientset) {
+	var lastErr error
+	for attempt := 1; attempt <= routeDaemonAttempts; attempt++ {
+		routeDataMutex.Lock()
+		if attempt > 1 {
+			generation, err := kube.UpdateConfigMap(kubectl, namespace, configMapName, routeConfigMapData)
+			if err != nil {
+				routeDataMutex.Unlock()
+				log.Printf("ERROR: %v", err)
+				lastErr = err
+				continue
+			}
+			routeGeneration = generation
+			log.Printf("   config map generation: %v", routeGeneration)
+		}
+		generation := routeGeneration
+		routeDataMutex.Unlock()
+		log.Printf("Wait for the route daemon on worker node %s to apply the SNAT rules for generation %s (attempt %d of %d)",
+			nodeName, generation, attempt, routeDaemonAttempts)
+		status, err := waitRouteDaemonStatus(kubectl, generation)
+		if err == nil {
+			log.Printf("Route daemon applied generation %s at %s.  Continue with VPN pod start up logic", status.Generation, status.Time)
+			return
+		}
+		log.Printf("ERROR: %v", err)
+		lastErr = err
+	}
+	log.Fatalf("ERROR: Route daemon on worker node %s did not apply the SNAT rules after %d attempts: %v", nodeName, routeDaemonAttempts, lastErr)
+}
+
+// Poll the status config map until the route daemon on this node reports the generation as applied
+func waitRouteDaemonStatus(kubectl *kubernetes.Clientset, generation string) (kube.RouteStatus, error) {
+	lastState := "route daemon has not published any status"
+	deadline := time.Now().Add(routeDaemonTimeout)
+	for time.Now().Before(deadline) {
+		statusMap, err := kube.GetRouteStatus(kubectl, namespace, kube.StatusConfigMapName(releaseName))
+		if err != nil {
+			lastState = err.Error()
+		} else if status, ok := statusMap[nodeName]; ok {
+			switch status.State(generation) {
+			case kube.RouteStateInSync:
+				return status, nil
+			case kube.RouteStateFailing:
+				lastState = fmt.Sprintf("generation %s failed: %s", status.Generation, status.Error)
+			default:
+				lastState = fmt.Sprintf("generation %s was applied", status.Generation)
+			}
+		}
+		time.Sleep(routeDaemonPollInterval)
+	}
+	return kube.RouteStatus{}, fmt.Errorf("timed out after %v waiting for generation %s on worker node %s, last state: %s",
+		routeDaemonTimeout, generation, nodeName, lastState)
+}
@@ -0,0 +1,303 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/IBM-Cloud/iks-strongswan/kube"
+	"github.com/IBM-Cloud/iks-strongswan/monitoring"
+	"github.com/IBM-Cloud/iks-strongswan/network"
+	"github.com/IBM-Cloud/iks-strongswan/utils"
+)
+
+// Various constants
+const (
+	envVarExposureMode     = "EXPOSURE_MODE"
+	envVarExternalIP       = "EXTERNAL_IP"
+	envVarLoadBalancerDNS  = "LOAD_BALANCER_RESOLVE_INTERVAL"
+	defaultResolveInterval = "60"
+
+	loadBalancerRetryInterval = 30 * time.Second // Delay before a load balancer IP that could not be applied is retried
+)
+
+// Ways that UDP ports 500 and 4500 of the VPN pod are exposed
+const (
+	exposureLoadBalancer = "loadBalancer" // Load Balancer service, the ingress IP or hostname of the service is used
+	exposureNodePort     = "nodePort"     // NodePort service, the public IP of the worker node is used
+	exposureHostNetwork  = "hostNetwork"  // VPN pod uses the host network, the public IP of the worker node is used
+	exposureExternalIP   = "externalIP"   // Service with an external IP that is routed to the worker nodes
+)
+
+var exposureMode string                       // How the VPN is exposed, determines where the load balancer IP is read from
+var externalIP string                         // IP address of the service with exposureExternalIP
+var loadBalancerKubectl *kubernetes.Clientset // Client used to apply load balancer IP changes
+var loadBalancerResolve time.Duration         // How often the hostname of the load balancer is resolved again
+var loadBalancerService *corev1.Service       // Last version of the load balancer service that was seen
+var loadBalancerServiceMutex sync.Mutex       // Serialize access to loadBalancerService
+var loadBalancerApplyMutex sync.Mutex         // Serialize the changes of the load balancer IP
+var loadBalancerRetryPending bool             // A failed change of the load balancer IP will be retried
+var appliedLeftID string                      // Value set in the leftid of ipsec.conf for leftid=%loadBalancerIP
+
+// Validate the exposure mode of the VPN pod
+func initExposure() {
+	exposureMode = os.Getenv(envVarExposureMode)
+	switch exposureMode {
+	case "":
+		exposureMode = exposureLoadBalancer
+	case exposureLoadBalancer, exposureNodePort:
+	case exposureHostNetwork:
+		// The nat rules of the VPN pod are in the chains of the worker node, which are shared with the route daemons
+		network.SetFirewallLockFile(firewallLockFile)
+	case exposureExternalIP:
+		externalIP = os.Getenv(envVarExternalIP)
+		if net.ParseIP(externalIP) == nil {
+			log.Fatalf("ERROR: Invalid value specified for %s: %s", envVarExternalIP, externalIP)
+		}
+	default:
+		log.Fatalf("ERROR: Invalid value specified for %s: %s", envVarExposureMode, exposureMode)
+	}
+	interval := os.Getenv(envVarLoadBalancerDNS)
+	if interval == "" {
+		interval = defaultResolveInterval
+	}
+	seconds, err := strconv.Atoi(interval)
+	if err != nil || seconds < 0 {
+		log.Fatalf("ERROR: Invalid value specified for %s: %s", envVarLoadBalancerDNS, interval)
+	}
+	loadBalancerResolve = time.Duration(seconds) * time.Second
+
+	if zoneLoadBalancer != "" && exposureMode != exposureLoadBalancer {
+		log.Printf("WARNING: zoneLoadBalancer is not supported with exposure %s and is ignored", exposureMode)
+		zoneLoadBalancer = ""
+	}
+
+	// The load balancer IP can only be the source of the VPN connection if it is routed to the worker nodes
+	if connectUsingVip && (exposureMode == exposureNodePort || exposureMode == exposureHostNetwork) {
+		log.Printf("WARNING: connectUsingLoadBalancerIP is not supported with exposure %s and is ignored", exposureMode)
+		connectUsingVip = false
+	}
+}
+
+// Determine the IP address that the VPN is reached on.  With exposureNodePort and exposureHostNetwork, the remote
+// gateway connects to the public IP of the worker node, which is used in place of the load balancer IP
+func getExposureIP(kubectl *kubernetes.Clientset, nodePublicIP string) (string, error) {
+	switch exposureMode {
+	case exposureNodePort, exposureHostNetwork:
+		if nodePublicIP == "" {
+			return "", fmt.Errorf("worker node %s does not have a public IP, which is required by exposure %s", nodeName, exposureMode)
+		}
+		return nodePublicIP, nil
+	case exposureExternalIP:
+		return externalIP, nil
+	}
+	return kube.GetLoadBalancerIP(kubectl, namespace, serviceName, requestedLoadBalancerIP, ipsecAuto, connectUsingVip)
+}
+
+// Watch the load balancer service of the VPN pod, so an IP that is assigned or changed after start up is applied.
+// Load balancers that publish a hostname are resolved again periodically, since the service does not change when
+// the addresses of the hostname do
+func startLoadBalancerWatch(kubectl *kubernetes.Clientset) {
+	if disableRouting || exposureMode != exposureLoadBalancer {
+		return
+	}
+	loadBalancerKubectl = kubectl
+	kube.WatchService(kubectl, namespace, serviceName, loadBalancerServiceChanged, nil,
+		func(oldObj, newObj interface{}) {
+			loadBalancerServiceChanged(newObj)
+		})
+	if loadBalancerResolve > 0 {
+		go loadBalancerResolveThread()
+	}
+}
+
+// Resolve the hostname of the load balancer again
+func loadBalancerResolveThread() {
+	for {
+		time.Sleep(loadBalancerResolve)
+		loadBalancerServiceMutex.Lock()
+		service := loadBalancerService
+		loadBalancerServiceMutex.Unlock()
+		if service == nil {
+			// Not delivered by the watch yet
+			var err error
+			service, err = loadBalancerKubectl.CoreV1().Services(namespace).Get(context.TODO(), serviceName, metav1.GetOptions{})
+			if err != nil {
+				log.Printf("WARNING: Unable to get service %s/%s: %v", namespace, serviceName, err)
+				continue
+			}
+		}
+		if hasIngressHostname(service) {
+			loadBalancerServiceChanged(service)
+		}
+	}
+}
+
+// Does the load balancer publish a hostname
+func hasIngressHostname(service *corev1.Service) bool {
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP == "" && ingress.Hostname != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Add/update event for the load balancer service
+func loadBalancerServiceChanged(obj interface{}) {
+	service, ok := obj.(*corev1.Service)
+	if !ok {
+		return
+	}
+	loadBalancerServiceMutex.Lock()
+	loadBalancerService = service
+	loadBalancerServiceMutex.Unlock()
+
+	loadBalancerApplyMutex.Lock()
+	defer loadBalancerApplyMutex.Unlock()
+	routeDataMutex.Lock()
+	currentIP, currentIPv6 := loadBalancerIP, routeConfigMapData.LoadBalancerIPv6
+	routeDataMutex.Unlock()
+	newIP, newIPv6 := kube.GetLoadBalancerIngressIPs(service, currentIP, currentIPv6)
+	if newIP == "" {
+		if currentIP != "<pending>" {
+			log.Printf("WARNING: Load balancer service %s no longer has an IP, keeping %s", serviceName, currentIP)
+		}
+		return
+	}
+	if !network.IsIPv6(remoteGateway) {
+		newIPv6 = ""
+	}
+	if newIP == currentIP && newIPv6 == currentIPv6 {
+		return
+	}
+	if err := applyLoadBalancerIP(currentIP, currentIPv6, newIP, newIPv6); err != nil {
+		log.Printf("ERROR: %v", err)
+		if !loadBalancerRetryPending {
+			log.Printf("Applying the load balancer IP again in %v", loadBalancerRetryInterval)
+			loadBalancerRetryPending = true
+			time.AfterFunc(loadBalancerRetryInterval, retryLoadBalancerIP)
+		}
+	}
+}
+
+// Apply the last version of the load balancer service again, after the load balancer IP could not be applied.  The
+// service may not change again, so the watch would not deliver it
+func retryLoadBalancerIP() {
+	loadBalancerApplyMutex.Lock()
+	loadBalancerRetryPending = false
+	loadBalancerApplyMutex.Unlock()
+	loadBalancerServiceMutex.Lock()
+	service := loadBalancerService
+	loadBalancerServiceMutex.Unlock()
+	loadBalancerServiceChanged(service)
+}
+
+// Re-run the configuration steps that depend on the load balancer IP.  The routes config map is published first, so
+// the load balancer IP is only changed once the route daemons can apply it.  Caller must hold loadBalancerApplyMutex
+func applyLoadBalancerIP(oldIP, oldIPv6, newIP, newIPv6 string) error {
+	// The preferred route table is derived from the load balancer IP
+	var tables *kube.TableAllocation
+	if newIP != oldIP {
+		allocated, err := kube.AllocateRouteTables(loadBalancerKubectl, namespace, releaseName, kube.CalculateRouterTable(newIP))
+		if err != nil {
+			log.Printf("WARNING: Unable to allocate route tables from %s/%s: %v", kube.RegistryNamespace, kube.RegistryName, err)
+		} else {
+			tables = &allocated
+			log.Printf("   route table: %v", tables.RouteTable)
+		}
+	}
+
+	// The route daemons apply the SNAT to the new load balancer IP from the routes config map.  The update is only
+	// attempted once, since routeDataMutex is held.  If it fails, it is retried by the caller
+	routeDataMutex.Lock()
+	routeData := routeConfigMapData
+	routeData.LoadBalancerIP = newIP
+	routeData.LoadBalancerIPv6 = newIPv6
+	if tables != nil {
+		routeData.RouteTable = tables.RouteTable
+		routeData.RulePriority = tables.RulePriority
+		routeData.TunnelTable = tables.TunnelTable
+	}
+	// connectUsingLoadBalancerIP was requested, but could not be used until the IP was assigned
+	enableVip := false
+	if connectUsingVipPending {
+		if err := disableGatewaySNAT(); err != nil {
+			log.Printf("ERROR: %v", err)
+		} else {
+			enableVip = true
+		}
+	}
+	routeData.ConnectUsingLB = strconv.FormatBool(connectUsingVip || enableVip)
+	generation, err := kube.UpdateConfigMapOnce(loadBalancerKubectl, namespace, configMapName, routeData)
+	if err != nil {
+		routeDataMutex.Unlock()
+		return fmt.Errorf("unable to publish the load balancer IP %s: %v", newIP, err)
+	}
+	loadBalancerIP = newIP
+	routeConfigMapData = routeData
+	routeGeneration = generation
+	if enableVip {
+		connectUsingVip = true
+		connectUsingVipPending = false
+	}
+	log.Printf("   config map generation: %v", routeGeneration)
+	routeDataMutex.Unlock()
+
+	// The VPN connects from the load balancer IP once the route daemon on this node has applied the SNAT rules, in the
+	// same way as at start up
+	if enableVip {
+		waitForRouteDaemon(loadBalancerKubectl)
+	}
+
+	// The local ID of the VPN is the load balancer IP, so strongSwan needs to reload the connection.  The leftid that
+	// was applied is tracked separately, since it is set before the IP is published at start up
+	if leftID == utils.LeftIDLoadBalancerIP && newIP != appliedLeftID {
+		if err := utils.ReplaceConfigLeftID(filepath.Join(ipsecEtcDir, ipsecConf), appliedLeftID, newIP); err != nil {
+			log.Printf("ERROR: Unable to update the local.id: %v", err)
+		} else {
+			appliedLeftID = newIP
+			reloadStrongswanConfig()
+		}
+	}
+
+	message := fmt.Sprintf("Load balancer IP of %s changed from %s to %s", releaseName, oldIP, newIP)
+	if newIPv6 != oldIPv6 {
+		message += fmt.Sprintf(", IPv6 changed from %s to %s", oldIPv6, newIPv6)
+	}
+	monitoring.Notify(message)
+	if requestedLoadBalancerIP != "" && newIP != requestedLoadBalancerIP {
+		log.Printf("WARNING: Load balancer service was assigned %s instead of the requested IP %s", newIP, requestedLoadBalancerIP)
+	}
+	return nil
+}
+
+// Reload ipsec.conf, connections that were changed are restarted by strongSwan
+func reloadStrongswanConfig() {
+	outBytes, err := exec.Command("sudo", "/usr/sbin/ipsec", "update").CombinedOutput() // #nosec G204 variables used are hard coded compile time constants
+	if err != nil {
+		log.Printf("WARNING: Failed to reload the strongSwan configuration: %v - %s", err, strings.TrimSpace(string(outBytes)))
+	}
+}
@@ -17,33 +17,42 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"k8s.io/client-go/kubernetes"
 
-	"github.com/IBM-Cloud/iks-strongswan/calico"
+	"github.com/IBM-Cloud/iks-strongswan/cni"
 	"github.com/IBM-Cloud/iks-strongswan/kube"
 	"github.com/IBM-Cloud/iks-strongswan/network"
+	"github.com/IBM-Cloud/iks-strongswan/platform"
 	"github.com/IBM-Cloud/iks-strongswan/utils"
 )
 
 // Various constants
 const (
 	envVarBuildDate       = "BUILD_DATE"
+	envVarCNI             = "CNI"
 	envVarDisableRouting  = "DISABLE_ROUTING"
 	envVarDisableVpn      = "DISABLE_VPN"
+	envVarFirewallBackend = "FIREWALL_BACKEND"
+	envVarKubeRetry       = "KUBE_RETRY_TIMEOUT"
 	envVarLocalSubnetNAT  = "LOCAL_SUBNET_NAT"
 	envVarNamespace       = "NAMESPACE"
 	envVarPodIP           = "POD_IP"
+	envVarPlatform        = "PLATFORM"
 	envVarPodName         = "POD_NAME"
 	envVarReleaseName     = "RELEASE_NAME"
 	envVarRemoteSubnetNAT = "REMOTE_SUBNET_NAT"
+	envVarRouteBackend    = "ROUTE_BACKEND"
 	envVarRouteDaemon     = "ROUTE_DAEMON"
 	envVarRunHelmTest     = "RUN_HELM_TEST"
 
-	runHelmCommand = "/usr/local/bin/runHelmTest"
+	firewallLockFile = "/run/strongswan-firewall.lock" // hostPath file on the worker node, shared by the route daemons and host network VPN pods
+	readyFile        = "/tmp/strongswan.ready"         // Checked by the readiness and liveness probes of the route daemon
+	runHelmCommand   = "/usr/local/bin/runHelmTest"
 )
 
 var configMapName string
@@ -76,7 +85,7 @@ func handleSignal() {
 	log.Print("Signal caught, terminating process...")
 	vpnPodCleanup()
 	strongswan.Stop()
-	handleRoutes(savedRouteMap, network.NetActionDelete)
+	deleteAllRoutes()
 	signalReceivedChan <- "Signal"
 	log.Print("Exiting signal handler")
 }
@@ -112,6 +121,50 @@ func initialize() {
 	}
 	if strings.ToLower(os.Getenv(envVarRouteDaemon)) == "true" {
 		routeDaemon = true
+		network.SetFirewallLockFile(firewallLockFile)
+	}
+	if err := network.SetRouteBackend(strings.ToLower(os.Getenv(envVarRouteBackend))); err != nil {
+		log.Fatalf("ERROR: Invalid value specified for %s: %v", envVarRouteBackend, err)
+	}
+	if err := network.SetFirewallBackend(strings.ToLower(os.Getenv(envVarFirewallBackend))); err != nil {
+		log.Fatalf("ERROR: Invalid value specified for %s: %v", envVarFirewallBackend, err)
+	}
+	initKubeRetryPolicy()
+}
+
+// Determine how long the kube helpers retry API calls that fail with a transient error
+func initKubeRetryPolicy() {
+	timeout := os.Getenv(envVarKubeRetry)
+	if timeout == "" {
+		return
+	}
+	seconds, err := strconv.Atoi(timeout)
+	if err != nil || seconds <= 0 {
+		log.Fatalf("ERROR: Invalid value specified for %s: %s", envVarKubeRetry, timeout)
+	}
+	policy := kube.DefaultRetryPolicy
+	policy.Deadline = time.Duration(seconds) * time.Second
+	kube.SetRetryPolicy(policy)
+}
+
+// Select the provider of the cluster metadata for the platform that the cluster runs on.  Local non cluster subnets
+// that overlap the pod or service subnets of the cluster are reported
+func initPlatform(kubectl *kubernetes.Clientset, nodeName, nodeIP string) {
+	if err := platform.Init(kubectl, strings.ToLower(os.Getenv(envVarPlatform)), nodeName, nodeIP); err != nil {
+		log.Fatalf("ERROR: Invalid value specified for %s: %v", envVarPlatform, err)
+	}
+	log.Printf("   platform: %v", platform.Get().Name())
+	if nonClusterSubnet == "" {
+		return
+	}
+	for _, clusterSubnet := range platform.Get().ClusterSubnets(kubectl) {
+		_, clusterNet, _ := net.ParseCIDR(clusterSubnet)
+		for _, subnet := range strings.Split(nonClusterSubnet, ",") {
+			_, subnetNet, err := net.ParseCIDR(subnet)
+			if err == nil && (clusterNet.Contains(subnetNet.IP) || subnetNet.Contains(clusterNet.IP)) {
+				log.Printf("WARNING: localNonClusterSubnet %s overlaps the cluster subnet %s", subnet, clusterSubnet)
+			}
+		}
 	}
 }
 
@@ -158,7 +211,7 @@ func validateNATrules(rules, name string) {
 		if err != nil {
 			log.Fatalf("ERROR: Invalid translated CIDR specified in %sSubnetNAT: %s", name, external)
 		}
-		if intNet != nil && extNet != nil && intNet.Mask.String() != extNet.Mask.String() && !strings.HasSuffix(external, "/32") {
+		if intNet != nil && extNet != nil && intNet.Mask.String() != extNet.Mask.String() && !strings.HasSuffix(external, "/32") && !strings.HasSuffix(external, "/128") {
 			log.Fatalf("ERROR: The original/translated CIDR mapping in %sSubnetNAT must be networks of the same size: %s", name, rule)
 		}
 	}
@@ -167,6 +220,10 @@ func validateNATrules(rules, name string) {
 // main routine
 func main() {
 	log.SetFlags(log.Ldate | log.Lmicroseconds) // Display microseconds
+	if len(os.Args) > 1 && os.Args[1] == "status" {
+		runStatusCommand()
+		return
+	}
 	log.Printf("Starting strongswan: %s", os.Getenv(envVarBuildDate))
 
 	// Initialize global vars
@@ -176,20 +233,46 @@ func main() {
 	go handleSignal()
 	time.Sleep(time.Second)
 
-	// Initialize the calico config and secrets
+	// Select the pod network and initialize the calico config
 	var kubectl *kubernetes.Clientset
 	if !disableRouting {
 		kubectl = kube.GetClient()
-		calico.Initialize()
+		if err := cni.Init(kubectl, strings.ToLower(os.Getenv(envVarCNI)), routeDaemon); err != nil {
+			log.Fatalf("ERROR: Unable to select the pod network (%s): %v", envVarCNI, err)
+		}
+		log.Printf("Pod network: %s", cni.Get().Name())
 	}
 
 	// Run the route daemon logic if requested
 	if routeDaemon {
 		// Route daemon specific initialization
-		routeDaemonInit()
+		routeDaemonInit(kubectl)
+		if err := os.WriteFile(readyFile, []byte{}, 0600); err != nil {
+			log.Fatalf("ERROR: Failed to write %s: %v", readyFile, err)
+		}
+
+		// Watch the pods on this node, for releases that only route selected pods through the VPN.  The rules of those
+		// releases are planned from the pods on the node, so all of the pods are listed before the config maps
+		if nodeName != "" {
+			podsSynced := kube.WatchPodsOnNode(kubectl, nodeName, podCreated, podDeleted, podUpdated)
+			for !podsSynced() {
+				time.Sleep(time.Second)
+			}
+		}
 
 		// Watch for config map changes
-		kube.WatchConfigMap(kubectl, namespace, configMapName, configMapCreated, configMapDeleted, configMapUpdated)
+		var synced func() bool
+		if routeDaemonSelector != "" {
+			synced = kube.WatchConfigMapsBySelector(kubectl, routeDaemonSelector, configMapCreated, configMapDeleted, configMapUpdated)
+		} else {
+			synced = kube.WatchConfigMap(kubectl, namespace, configMapName, configMapCreated, configMapDeleted, configMapUpdated)
+		}
+
+		// Remove the routes of config maps that were deleted while the route daemon was not running
+		go removeStaleRoutes(synced)
+
+		// Periodically repair any drift in the routes / rules on the node
+		go reconcileThread()
 		log.Print("Waiting for config map updates...")
 		<-signalReceivedChan
 		log.Print("Breaking out of loop")
@@ -202,7 +285,7 @@ func main() {
 		defer vpnPodCleanup()
 
 		// Wait for the route daemon on this node to configure iptable rules
-		vpnPodWaitRouteDaemon()
+		vpnPodWaitRouteDaemon(kubectl)
 
 		// Start ipsec and wait for it to end
 		if !disableVpn {
@@ -0,0 +1,399 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/IBM-Cloud/iks-strongswan/kube"
+	"github.com/IBM-Cloud/iks-strongswan/network"
+)
+
+// Kinds of operations in a route plan
+const (
+	opRoute     = "route"     // ip route add/del/replace
+	opRule      = "rule"      // ip rule add/del
+	opNAT       = "nat"       // SNAT / MASQUERADE rule add/del
+	opNATFlush  = "nat-flush" // Remove all of the nat rules of the release
+	opConntrack = "conntrack" // Delete the conntrack entry from the remote gateway to the load balancer IP
+)
+
+// nodeInfo - Everything the planner needs to know about the worker node the route daemon is running on
+type nodeInfo struct {
+	ip                string                // Private IP of the worker node
+	subnet            string                // Subnet of the worker node
+	routingTable      []network.RoutingInfo // Main routing table of the worker node
+	workerNodeDevices map[string]string     // Device used to reach the worker node of each VPN pod, keyed by worker node IP
+	vpnPodDevices     map[string]string     // Device used to reach the VPN pods on the node that did not publish one, keyed by VPN pod IP
+	overlayDevice     string                // Device of the pod network that encapsulates traffic to other subnets (ex: tunl0), empty if none
+	pods              []podInfo             // Pods running on the worker node
+	zone              string                // Zone of the worker node, empty if not known
+}
+
+// routeOperation - Single change to the routes, rules, nat rules or conntrack entries of the worker node
+type routeOperation struct {
+	kind   string
+	action network.NetAddDelAction
+	owner  string // Release that owns the nat rules
+	route  routeEntry
+	rule   ruleEntry
+	snat   snatEntry
+}
+
+// String - Display the operation in a format similar to the command that is run
+func (op routeOperation) String() string {
+	switch op.kind {
+	case opRoute:
+		return fmt.Sprintf("ip route %s %s %s", op.action, op.route.subnet, op.route.routeInfo)
+	case opRule:
+		if op.action == network.NetActionAdd {
+			return fmt.Sprintf("ip rule %s from %s table %s prior %s", op.action, op.rule.fromSource, op.rule.routeTable, op.rule.priority)
+		}
+		return fmt.Sprintf("ip rule %s from %s table %s", op.action, op.rule.fromSource, op.rule.routeTable)
+	case opNAT:
+		return fmt.Sprintf("nat %s %s (%s)", op.action, network.SNATRule(op.snat.remoteGateway, op.snat.vpnPodIP, op.snat.loadBalancerIP), op.owner)
+	case opNATFlush:
+		return fmt.Sprintf("nat flush (%s)", op.owner)
+	case opConntrack:
+		return fmt.Sprintf("conntrack -D -s %s -d %s -p udp", op.snat.remoteGateway, op.snat.loadBalancerIP)
+	}
+	return op.kind
+}
+
+// Does the routing table of the node contain overlay routes, i.e. is encapsulation needed to reach another worker node
+func (node nodeInfo) hasTunnel() bool {
+	if node.overlayDevice == "" {
+		return false
+	}
+	for _, route := range node.routingTable {
+		if route.Dev == node.overlayDevice {
+			return true
+		}
+	}
+	return false
+}
+
+// Plan the operations that add (or delete) all of the routes, rules and SNAT rules of the release on the node.
+// If flushNAT is set, the nat rules left behind by a previous route daemon are removed first
+func planRoutes(release routeRelease, node nodeInfo, addDelAction network.NetAddDelAction, flushNAT bool) []routeOperation {
+	var plan []routeOperation
+	if addDelAction == network.NetActionAdd {
+		plan = planRouteChanges(release.owner, newRouteState(), planRouteState(release, node), flushNAT)
+	} else {
+		plan = planRouteChanges(release.owner, planRouteState(release, node), newRouteState(), flushNAT)
+	}
+	return append(plan, planConntrack(release.routeData)...)
+}
+
+// Determine the routes, rules and SNAT rules that the release needs on the node
+func planRouteState(release routeRelease, node nodeInfo) routeState {
+	state := newRouteState()
+	routeData := release.routeData
+	if routeData.RemoteSubnet == "" || routeData.RouteTable == "" || routeData.WorkerNodeIP == "" || routeData.WorkerSubnet == "" {
+		return state
+	}
+	remappedRemoteSubnet := release.remapRemoteSubnet()
+
+	if node.ip == routeData.WorkerNodeIP {
+		// If there are tunnels in the routing table, we may need to tunnel traffic from on-prem to diff subnet
+		if node.hasTunnel() {
+			tunnelTable := tunnelRouteTable(routeData)
+			tunnelSubnets := planTunnelSubnets(release, node)
+			for _, localSub := range tunnelSubnets {
+				state.addRoute(localSub, tunnelTable, "dev "+node.overlayDevice+" table "+tunnelTable)
+			}
+			if len(tunnelSubnets) > 0 {
+				for _, remoteSub := range strings.Split(remappedRemoteSubnet, ",") {
+					if !network.IsIPv6(remoteSub) { // The overlay devices only carry IPv4 traffic
+						state.addRule(remoteSub, tunnelTable, "")
+					}
+				}
+			}
+		}
+		// VPN pod is using host networking.  No other routes or SNAT rules are needed
+		if routeData.WorkerNodeIP == routeData.VpnPodIP {
+			return state
+		}
+		for _, subnet := range strings.Split(release.nonClusterSubnet, ",") {
+			if subnet != "" && subnet != routeData.WorkerSubnet {
+				state.addSNAT(subnet, "", "")
+			}
+		}
+	}
+
+	// Routes of a release in a tunnel group are only added if it is carrying the traffic of the group.  IPv4 and IPv6
+	// remote subnets are routed through the IPv4 and IPv6 address of the VPN pod / worker node
+	routeInfos := map[bool]string{false: planGroupRouteInfo(release, node, false), true: planGroupRouteInfo(release, node, true)}
+	routedFamilies := map[bool]bool{}
+	for _, subnet := range strings.Split(remappedRemoteSubnet, ",") {
+		_, networkAddr, err := net.ParseCIDR(subnet)
+		if err != nil {
+			continue
+		}
+		ipv6 := network.IsIPv6(networkAddr.String())
+		if routeInfos[ipv6] != "" {
+			state.addRoute(networkAddr.String(), routeData.RouteTable, routeInfos[ipv6])
+			routedFamilies[ipv6] = true
+		}
+	}
+	for _, fromSource := range planRuleSources(release, node) {
+		if routedFamilies[network.IsIPv6(fromSource)] {
+			state.addRule(fromSource, routeData.RouteTable, routeData.RulePriority)
+		}
+	}
+
+	if routeData.ConnectUsingLB == "true" {
+		if node.ip == routeData.WorkerNodeIP {
+			// Special SNAT rules are needed on the worker node where the VPN pod is running
+			if network.IsIPv6(routeData.RemoteGateway) {
+				state.addSNAT(routeData.RemoteGateway, routeData.VpnPodIPv6, routeData.LoadBalancerIPv6)
+			} else {
+				state.addSNAT(routeData.RemoteGateway, routeData.VpnPodIP, routeData.LoadBalancerIP)
+			}
+		}
+		// Since calico is configured to not NAT to the remote gateway, we need to add this rule on all worker nodes
+		state.addSNAT(routeData.RemoteGateway, "", "")
+	}
+	return state
+}
+
+// Does the release only route selected pods through the VPN
+func (release routeRelease) selectsPods() bool {
+	return release.routeData.RouteNamespaces != "" || release.routeData.RoutePodSelector != ""
+}
+
+// Determine the sources of the rules that send traffic to the routing table of the release.  Traffic from all of
+// the pods on the node uses the VPN, unless the release selected pods by namespace or label.  In that case there is a
+// rule for the IP of each selected pod on the node.  Both the IPv4 and IPv6 sources are returned
+func planRuleSources(release routeRelease, node nodeInfo) []string {
+	if !release.selectsPods() {
+		return []string{"all", network.RuleFromAllIPv6}
+	}
+	namespaces := map[string]bool{}
+	for _, namespace := range strings.Split(release.routeData.RouteNamespaces, ",") {
+		if namespace != "" {
+			namespaces[namespace] = true
+		}
+	}
+	selector, err := labels.Parse(release.routeData.RoutePodSelector)
+	if err != nil {
+		return nil // Validated by the VPN pod, no pods are selected
+	}
+	sources := []string{}
+	for _, pod := range node.pods {
+		if len(namespaces) > 0 && !namespaces[pod.namespace] {
+			continue
+		}
+		if !selector.Matches(labels.Set(pod.labels)) {
+			continue
+		}
+		sources = append(sources, pod.ip)
+		if pod.ipv6 != "" {
+			sources = append(sources, pod.ipv6)
+		}
+	}
+	return sources
+}
+
+// Determine the "via ... dev ... table ..." route info used to reach the VPN pod from the node, using the IPv4 or
+// IPv6 addresses of the VPN pod and its worker node.  An empty string is returned if the VPN pod is using host
+// networking on the node and no route is needed, if the VPN pod does not have an address of the IP family, or if
+// the device used to reach the VPN pod is not known
+func planRouteInfo(routeData kube.RouteData, node nodeInfo, ipv6 bool) string {
+	vpnPodIP, workerNodeIP := routeData.VpnPodIP, routeData.WorkerNodeIP
+	if ipv6 {
+		vpnPodIP, workerNodeIP = routeData.VpnPodIPv6, routeData.WorkerNodeIPv6
+	}
+	if vpnPodIP == "" || workerNodeIP == "" {
+		return ""
+	}
+	if node.ip == routeData.WorkerNodeIP {
+		if routeData.WorkerNodeIP == routeData.VpnPodIP {
+			return ""
+		}
+		vpnPodDevice := routeData.VpnPodDevice
+		if vpnPodDevice == "" {
+			vpnPodDevice = node.vpnPodDevices[vpnPodIP]
+		}
+		if vpnPodDevice == "" {
+			log.Printf("WARNING: Device used to reach VPN pod %s is not known.  Routes to it are not added", vpnPodIP)
+			return ""
+		}
+		return "via " + vpnPodIP + " dev " + vpnPodDevice + " table " + routeData.RouteTable
+	}
+	deviceName := node.workerNodeDevices[workerNodeIP]
+	if deviceName == "" {
+		log.Printf("WARNING: Device used to reach worker node %s is not known.  Routes to it are not added", workerNodeIP)
+		return ""
+	}
+	if node.subnet != routeData.WorkerSubnet || deviceName == node.overlayDevice {
+		return "via " + workerNodeIP + " dev " + deviceName + " onlink table " + routeData.RouteTable
+	}
+	return "via " + workerNodeIP + " dev " + deviceName + " table " + routeData.RouteTable
+}
+
+// Determine the route info for the remote subnets of a release in a tunnel group.  With failover, only the preferred
+// tunnel that is up gets the routes.  With ecmp, the preferred tunnel that is up gets a multipath route with a next
+// hop for each tunnel that is up.  If none of the tunnels are up, the preferred tunnel keeps the routes.  With zone
+// local routing, the tunnels in the zone of the node are preferred over the tunnels in other zones.
+// An empty string is returned if the release does not need any routes for the remote subnets of the IP family
+func planGroupRouteInfo(release routeRelease, node nodeInfo, ipv6 bool) string {
+	routeData := release.routeData
+	if len(release.group) == 0 {
+		return planRouteInfo(routeData, node, ipv6)
+	}
+	active := []routeRelease{}
+	for _, member := range release.group {
+		if member.routeData.TunnelState == kube.TunnelStateUp {
+			active = append(active, member)
+		}
+	}
+	if len(active) == 0 {
+		active = planZoneMembers(release.group, node)[:1]
+	} else {
+		active = planZoneMembers(active, node)
+	}
+	if active[0].key != release.key {
+		return ""
+	}
+	if release.group[0].routeData.TunnelGroupMode != kube.TunnelGroupECMP || len(active) == 1 {
+		return planRouteInfo(routeData, node, ipv6)
+	}
+
+	nextHops := []string{}
+	for _, member := range active {
+		memberRouteInfo := planRouteInfo(member.routeData, node, ipv6)
+		if memberRouteInfo == "" {
+			continue // VPN pod is using host networking on this node
+		}
+		weight := member.routeData.TunnelWeight
+		if weight == "" {
+			weight = "1"
+		}
+		nextHop := strings.TrimSuffix(memberRouteInfo, " table "+member.routeData.RouteTable)
+		nextHops = append(nextHops, "nexthop "+nextHop+" weight "+weight)
+	}
+	if len(nextHops) == 0 {
+		return ""
+	}
+	return "table " + routeData.RouteTable + " " + strings.Join(nextHops, " ")
+}
+
+// With zone local routing, only keep the tunnels of the group that are in the zone of the node.  All of the tunnels
+// are kept if none of them are in the zone of the node, so the traffic falls back to the other zones
+func planZoneMembers(members []routeRelease, node nodeInfo) []routeRelease {
+	if members[0].routeData.ZoneLocalRouting != "true" || node.zone == "" {
+		return members
+	}
+	local := []routeRelease{}
+	for _, member := range members {
+		if member.routeData.Zone == node.zone {
+			local = append(local, member)
+		}
+	}
+	if len(local) == 0 {
+		return members
+	}
+	return local
+}
+
+// Determine which of the local subnets of the release can only be reached from the node through the overlay device
+func planTunnelSubnets(release routeRelease, node nodeInfo) []string {
+	localSubnets := strings.Split(release.routeData.LocalSubnet, ",")
+	// With the introduction of local subnet NAT, we now need
+	// to examine the inside/internal local subnets too
+	if release.localSubnetNAT != "" {
+		for _, local := range strings.Split(release.localSubnetNAT, ",") {
+			localSubnets = append(localSubnets, strings.Split(local, "=")[0]) // Only look at inside network of the NAT
+		}
+	}
+	tunnelSubnets := []string{}
+	for _, localSub := range localSubnets { // For each local subnet shared
+		if localSub == node.subnet { // If current subnet, no tunnel needed
+			continue
+		}
+		if network.TunnelNeededToReachSubnet(localSub, node.overlayDevice, node.routingTable) {
+			tunnelSubnets = append(tunnelSubnets, localSub)
+		}
+	}
+	return tunnelSubnets
+}
+
+// Plan the operations that change the node from the old state to the new state.  New and changed entries are
+// applied before the entries that are no longer needed are removed, so traffic that is not affected by the change
+// keeps flowing.  Routes are updated in place with "ip route replace"
+func planRouteChanges(owner string, oldState, newState routeState, flushNAT bool) []routeOperation {
+	plan := []routeOperation{}
+	if flushNAT {
+		plan = append(plan, routeOperation{kind: opNATFlush, action: network.NetActionDelete, owner: owner})
+		oldState.snat = map[string]snatEntry{} // All of the nat rules of the release are removed by the flush
+	}
+	for _, key := range sortedKeys(newState.routes) {
+		route := newState.routes[key]
+		if old, ok := oldState.routes[key]; ok && old.routeInfo == route.routeInfo {
+			continue
+		}
+		plan = append(plan, routeOperation{kind: opRoute, action: network.NetActionReplace, route: route})
+	}
+	for _, key := range sortedKeys(newState.rules) {
+		rule := newState.rules[key]
+		old, ok := oldState.rules[key]
+		if ok && old.priority == rule.priority {
+			continue
+		}
+		if ok {
+			// Only the priority changed.  ip rules can not be replaced, so the old rule is removed first
+			plan = append(plan, routeOperation{kind: opRule, action: network.NetActionDelete, rule: old})
+		}
+		plan = append(plan, routeOperation{kind: opRule, action: network.NetActionAdd, rule: rule})
+	}
+	for _, key := range sortedKeys(newState.snat) {
+		if _, ok := oldState.snat[key]; !ok {
+			plan = append(plan, routeOperation{kind: opNAT, action: network.NetActionAdd, owner: owner, snat: newState.snat[key]})
+		}
+	}
+
+	// Routes are removed before the rules, since the "from all" rule of a table is not deleted while the table still
+	// has routes
+	for _, key := range sortedKeys(oldState.routes) {
+		if _, ok := newState.routes[key]; !ok {
+			plan = append(plan, routeOperation{kind: opRoute, action: network.NetActionDelete, route: oldState.routes[key]})
+		}
+	}
+	for _, key := range sortedKeys(oldState.rules) {
+		if _, ok := newState.rules[key]; !ok {
+			plan = append(plan, routeOperation{kind: opRule, action: network.NetActionDelete, rule: oldState.rules[key]})
+		}
+	}
+	for _, key := range sortedKeys(oldState.snat) {
+		if _, ok := newState.snat[key]; !ok {
+			plan = append(plan, routeOperation{kind: opNAT, action: network.NetActionDelete, owner: owner, snat: oldState.snat[key]})
+		}
+	}
+	return plan
+}
+
+// If we have a load balancer IP, plan the removal of any stale conntrack entry from the remote gateway to the load balancer IP
+func planConntrack(routeData kube.RouteData) []routeOperation {
+	loadBalancerIP := routeData.LoadBalancerIP
+	if network.IsIPv6(routeData.RemoteGateway) {
+		loadBalancerIP = routeData.LoadBalancerIPv6
+	}
+	if net.ParseIP(loadBalancerIP) == nil || net.ParseIP(routeData.RemoteGateway) == nil {
+		return nil
+	}
+	return []routeOperation{{kind: opConntrack, action: network.NetActionDelete, snat: snatEntry{remoteGateway: routeData.RemoteGateway, loadBalancerIP: loadBalancerIP}}}
+}
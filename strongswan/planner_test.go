@@ -0,0 +1,168 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/IBM-Cloud/iks-strongswan/kube"
+	"github.com/IBM-Cloud/iks-strongswan/network"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// Route data published by a VPN pod on worker node 10.1.1.10 in subnet 10.1.1.0/24
+func testRouteData() kube.RouteData {
+	return kube.RouteData{
+		ConnectUsingLB: "true",
+		LoadBalancerIP: "169.60.1.2",
+		LocalSubnet:    "172.30.0.0/16,10.1.1.0/24,10.2.2.0/24",
+		RemoteGateway:  "192.168.10.1",
+		RemoteSubnet:   "192.168.20.0/24,192.168.30.0/24",
+		RouteTable:     "201",
+		RulePriority:   "201",
+		TunnelTable:    "199",
+		VpnPodDevice:   "cali1234567890a",
+		VpnPodIP:       "172.30.5.6",
+		WorkerNodeIP:   "10.1.1.10",
+		WorkerSubnet:   "10.1.1.0/24",
+	}
+}
+
+// Build a release of the route data in the default namespace
+func testRelease(routeData kube.RouteData) routeRelease {
+	return routeRelease{key: "default/vpn-strongswan-routes", namespace: "default", releaseName: "vpn", owner: "default.vpn", routeData: routeData}
+}
+
+// Worker node that reaches the VPN worker node on eth0.  tunl0 routes lead to the worker nodes in 10.2.2.0/24
+func testNode(ip, subnet string) nodeInfo {
+	return nodeInfo{
+		ip:     ip,
+		subnet: subnet,
+		routingTable: []network.RoutingInfo{
+			{Dest: "default", Via: "10.1.1.1", Dev: "eth0"},
+			{Dest: "172.30.8.0/26", Via: "10.2.2.20", Dev: "tunl0"},
+		},
+		workerNodeDevices: map[string]string{"10.1.1.10": "eth0"},
+		vpnPodDevices:     map[string]string{},
+		overlayDevice:     "tunl0",
+	}
+}
+
+// Format the route state and the operations that add and delete it, in the format of the golden files
+func formatPlan(release routeRelease, node nodeInfo) string {
+	var out strings.Builder
+	state := planRouteState(release, node)
+	fmt.Fprintln(&out, "# state")
+	for _, key := range sortedKeys(state.routes) {
+		fmt.Fprintf(&out, "route %s %s\n", state.routes[key].subnet, state.routes[key].routeInfo)
+	}
+	for _, key := range sortedKeys(state.rules) {
+		rule := state.rules[key]
+		fmt.Fprintf(&out, "rule from %s table %s prior %s\n", rule.fromSource, rule.routeTable, rule.priority)
+	}
+	for _, key := range sortedKeys(state.snat) {
+		snat := state.snat[key]
+		fmt.Fprintf(&out, "snat %s\n", network.SNATRule(snat.remoteGateway, snat.vpnPodIP, snat.loadBalancerIP))
+	}
+	for _, action := range []network.NetAddDelAction{network.NetActionAdd, network.NetActionDelete} {
+		fmt.Fprintf(&out, "# %s\n", action)
+		for _, op := range planRoutes(release, node, action, false) {
+			fmt.Fprintln(&out, op)
+		}
+	}
+	return out.String()
+}
+
+// Compare the output with testdata/<name>.golden.  The golden files are rewritten with: go test -run Plan -update
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	golden := filepath.Join("testdata", name+".golden")
+	if *updateGolden {
+		if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("plan does not match %s\n--- got:\n%s--- want:\n%s", golden, got, want)
+	}
+}
+
+func TestPlanRouteState(t *testing.T) {
+	hostNetwork := testRouteData()
+	hostNetwork.VpnPodIP = hostNetwork.WorkerNodeIP
+	missingDevice := testNode("10.1.1.11", "10.1.1.0/24")
+	missingDevice.workerNodeDevices = map[string]string{}
+
+	tests := []struct {
+		name    string
+		release routeRelease
+		node    nodeInfo
+	}{
+		{"same-node", testRelease(testRouteData()), testNode("10.1.1.10", "10.1.1.0/24")},
+		{"same-node-host-network", testRelease(hostNetwork), testNode("10.1.1.10", "10.1.1.0/24")},
+		{"same-subnet", testRelease(testRouteData()), testNode("10.1.1.11", "10.1.1.0/24")},
+		{"cross-subnet", testRelease(testRouteData()), testNode("10.2.2.20", "10.2.2.0/24")},
+		{"tunl0-device", testRelease(testRouteData()), func() nodeInfo {
+			// Same subnet, but the worker node is reached through the overlay device
+			node := testNode("10.1.1.11", "10.1.1.0/24")
+			node.workerNodeDevices = map[string]string{"10.1.1.10": "tunl0"}
+			return node
+		}()},
+		{"missing-device", testRelease(testRouteData()), missingDevice},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkGolden(t, test.name, formatPlan(test.release, test.node))
+		})
+	}
+}
+
+func TestPlanRouteChanges(t *testing.T) {
+	oldData := testRouteData()
+	newData := testRouteData()
+	newData.RemoteSubnet = "192.168.30.0/24,192.168.40.0/24"
+	newData.RulePriority = "150"
+	tableData := testRouteData()
+	tableData.RouteTable = "202"
+	tableData.RulePriority = "202"
+
+	tests := []struct {
+		name    string
+		oldData kube.RouteData
+		newData kube.RouteData
+		node    nodeInfo
+	}{
+		{"change-subnets", oldData, newData, testNode("10.1.1.11", "10.1.1.0/24")},
+		{"change-table", oldData, tableData, testNode("10.1.1.11", "10.1.1.0/24")},
+		{"change-none", oldData, oldData, testNode("10.1.1.10", "10.1.1.0/24")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oldState := planRouteState(testRelease(test.oldData), test.node)
+			newState := planRouteState(testRelease(test.newData), test.node)
+			var out strings.Builder
+			for _, op := range planRouteChanges("default.vpn", oldState, newState, false) {
+				fmt.Fprintln(&out, op)
+			}
+			checkGolden(t, test.name, out.String())
+		})
+	}
+}
@@ -0,0 +1,124 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"log"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/IBM-Cloud/iks-strongswan/network"
+)
+
+// podInfo - Pod running on this worker node that may be allowed to use the VPN
+type podInfo struct {
+	namespace string
+	name      string
+	ip        string
+	ipv6      string // Empty in a single stack IPv4 cluster
+	labels    map[string]string
+}
+
+var nodePods = map[string]podInfo{} // Pods running on this worker node, keyed by "<namespace>/<pod name>"
+
+// Extract the pod information.  Pods without an IP, using host networking or that have completed are ignored
+func podFromObject(obj interface{}) (podInfo, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return podInfo{}, false
+	}
+	info := podInfo{namespace: pod.Namespace, name: pod.Name, ip: pod.Status.PodIP, labels: pod.Labels}
+	for _, podIP := range pod.Status.PodIPs {
+		if network.IsIPv6(podIP.IP) {
+			info.ipv6 = podIP.IP
+		}
+	}
+	if pod.Spec.HostNetwork || pod.Status.PodIP == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return info, false
+	}
+	return info, true
+}
+
+// Pod was created on this worker node
+func podCreated(obj interface{}) {
+	if pod, ok := podFromObject(obj); ok {
+		updatePodRules(func() { nodePods[pod.namespace+"/"+pod.name] = pod })
+	}
+}
+
+// Pod was deleted from this worker node
+func podDeleted(obj interface{}) {
+	pod, _ := podFromObject(obj)
+	updatePodRules(func() { delete(nodePods, pod.namespace+"/"+pod.name) })
+}
+
+// Pod was updated.  The pod IP is assigned, labels change and pods complete after the pod was created
+func podUpdated(_, newObj interface{}) {
+	pod, ok := podFromObject(newObj)
+	updatePodRules(func() {
+		if ok {
+			nodePods[pod.namespace+"/"+pod.name] = pod
+		} else {
+			delete(nodePods, pod.namespace+"/"+pod.name)
+		}
+	})
+}
+
+// Apply the pod changes to the list of pods on this node and update the rules of every release that only routes
+// selected pods through the VPN
+func updatePodRules(changePods func()) {
+	routeMutex.Lock()
+	defer routeMutex.Unlock()
+	oldStates := map[string]routeState{}
+	for key, cmData := range savedRouteMaps {
+		if release := newRouteRelease(key, cmData); release.selectsPods() {
+			oldStates[key] = appliedRouteState(release)
+		}
+	}
+	changePods()
+	changed := false
+	for key, oldState := range oldStates {
+		release := newRouteRelease(key, savedRouteMaps[key])
+		newState := planRouteState(release, currentNode(release))
+		recordRouteState(key, newState)
+		plan := planRouteChanges(release.owner, oldState, newState, false)
+		if len(plan) == 0 {
+			continue
+		}
+		log.Printf("Updating the pod rules of %s", key)
+		executeRoutePlan(plan)
+		publishRouteStatus(release)
+		changed = true
+	}
+	if changed {
+		saveRouteState()
+	}
+}
+
+// List the pods running on this node, sorted by namespace and name
+func sortedNodePods() []podInfo {
+	pods := make([]podInfo, 0, len(nodePods))
+	for _, pod := range nodePods {
+		pods = append(pods, pod)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].namespace != pods[j].namespace {
+			return pods[i].namespace < pods[j].namespace
+		}
+		return pods[i].name < pods[j].name
+	})
+	return pods
+}
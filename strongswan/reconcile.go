@@ -0,0 +1,252 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM-Cloud/iks-strongswan/network"
+)
+
+// Various constants
+const (
+	envVarReconcileInterval = "RECONCILE_INTERVAL"
+
+	defaultReconcileInterval = 60 // seconds
+)
+
+var reconcileInterval time.Duration // How often the route daemon verifies the routes / rules / SNAT rules on the node
+
+// Determine how often the reconcile logic should run.  A value of 0 disables reconciliation
+func initReconcileInterval() {
+	reconcileInterval = time.Duration(defaultReconcileInterval) * time.Second
+	interval := os.Getenv(envVarReconcileInterval)
+	if interval == "" {
+		return
+	}
+	seconds, err := strconv.Atoi(interval)
+	if err != nil || seconds < 0 {
+		log.Fatalf("ERROR: Invalid value specified for %s: %s", envVarReconcileInterval, interval)
+	}
+	reconcileInterval = time.Duration(seconds) * time.Second
+}
+
+// Periodically compare the routes, rules and SNAT rules on this node against the saved config map data and repair any drift
+func reconcileThread() {
+	if reconcileInterval == 0 || dryRun {
+		log.Print("Route reconciliation is disabled")
+		return
+	}
+	log.Printf("Route reconciliation will run every %v", reconcileInterval)
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		reconcileRoutes()
+	}
+}
+
+// Verify that the state on the node matches what was applied for the saved config map data of each release
+func reconcileRoutes() {
+	routeMutex.Lock()
+	defer routeMutex.Unlock()
+	drift := 0
+	for key, cmData := range savedRouteMaps {
+		release := newRouteRelease(key, cmData)
+		drift += reconcileRelease(release)
+		publishRouteStatus(release)
+	}
+	if drift > 0 {
+		saveRouteState()
+	}
+}
+
+// Verify the routes, rules and SNAT rules of a single release.  Returns the number of repairs made
+func reconcileRelease(release routeRelease) int {
+	routeData := release.routeData
+	if routeData.RemoteSubnet == "" || routeData.RouteTable == "" || routeData.WorkerNodeIP == "" || routeData.WorkerSubnet == "" {
+		return 0
+	}
+	node := currentNode(release)
+	state := planRouteState(release, node)
+
+	drift := reconcileStateRoutes(state)
+	for _, key := range sortedKeys(state.rules) {
+		rule := state.rules[key]
+		drift += reconcileRule(rule.fromSource, rule.routeTable, rule.priority)
+	}
+	// The routes and rules of the release are not planned while a VPN pod or worker node can not be reached, so the
+	// rules that are not part of the plan are only removed, and the state recorded, when all of them can be reached
+	if len(node.unreachableAddresses()) == 0 {
+		drift += reconcileExtraRules(state, routeData.RouteTable)
+		recordRouteState(release.key, state)
+	}
+	for _, key := range sortedKeys(state.snat) {
+		snat := state.snat[key]
+		drift += reconcileSNAT(release.owner, snat.remoteGateway, snat.vpnPodIP, snat.loadBalancerIP)
+	}
+	// The lookup of the probe does not come from a pod, so it only uses the routing table of the release when all
+	// of the pods on the node are routed through the VPN
+	if !release.selectsPods() {
+		verifyDataPath(state.tableRoutes(routeData.RouteTable))
+	}
+	if drift > 0 {
+		log.Printf("Reconcile: repaired %d drifted routes/rules for %s", drift, release.key)
+		network.ListRules()
+		network.ListRoutes(routeData.RouteTable)
+	}
+	return drift
+}
+
+// Verify the routes of the release are present and use the expected next hop.  Returns the number of repairs made
+func reconcileStateRoutes(state routeState) int {
+	drift := 0
+	for _, routeTable := range state.routeTables() {
+		actualRoutes, err := network.GetRoutes(routeTable)
+		if err != nil {
+			log.Printf("Reconcile: ERROR: %v", err)
+			continue
+		}
+		for _, expected := range state.tableRoutes(routeTable) {
+			via, dev := routeInfoNextHop(expected.routeInfo)
+			route, found := findRoute(actualRoutes, expected.subnet)
+			switch {
+			case !found:
+				log.Printf("Reconcile: DRIFT: route for %s is missing from table %s", expected.subnet, routeTable)
+			case isMultipath(expected.routeInfo):
+				continue // Only the presence of multipath routes is verified
+			case route.Via != via || route.Dev != dev:
+				log.Printf("Reconcile: DRIFT: route for %s in table %s is via %s dev %s, expected via %s dev %s", expected.subnet, routeTable, route.Via, route.Dev, via, dev)
+			default:
+				continue
+			}
+			network.UpdateRoute(network.NetActionReplace, expected.subnet, expected.routeInfo)
+			drift++
+		}
+	}
+	return drift
+}
+
+// Verify that the ip rule for the source and routing table exists.  Returns the number of repairs made
+func reconcileRule(fromSource, routeTable, priority string) int {
+	found, err := network.RuleExists(fromSource, routeTable)
+	if err != nil {
+		log.Printf("Reconcile: ERROR: %v", err)
+		return 0
+	}
+	if found {
+		return 0
+	}
+	log.Printf("Reconcile: DRIFT: rule from %s lookup %s is missing", fromSource, routeTable)
+	network.UpdateRouteRule(network.NetActionAdd, fromSource, routeTable, priority)
+	return 1
+}
+
+// Remove the rules that send traffic to the routing table of the release, but are not part of its planned state.  The
+// rule of a pod that was deleted while the route daemon was not running would send the traffic of the next pod that
+// is assigned the IP through the VPN.  Returns the number of repairs made
+func reconcileExtraRules(state routeState, routeTable string) int {
+	rules, err := network.GetRules()
+	if err != nil {
+		log.Printf("Reconcile: ERROR: %v", err)
+		return 0
+	}
+	drift := 0
+	for _, rule := range rules {
+		if rule.Table != routeTable {
+			continue
+		}
+		if _, ok := state.rules[rule.From+" "+routeTable]; ok {
+			continue
+		}
+		log.Printf("Reconcile: DRIFT: rule from %s lookup %s is not needed", rule.From, routeTable)
+		network.UpdateRouteRule(network.NetActionDelete, rule.From, routeTable, "")
+		drift++
+	}
+	return drift
+}
+
+// Verify that the SNAT / MASQUERADE rule exists.  Returns the number of repairs made
+func reconcileSNAT(owner, remoteGateway, vpnPodIP, loadBalancerIP string) int {
+	if network.SNATRuleExists(owner, remoteGateway, vpnPodIP, loadBalancerIP) {
+		return 0
+	}
+	if vpnPodIP != "" {
+		log.Printf("Reconcile: DRIFT: SNAT rule for %s from %s to %s is missing", remoteGateway, vpnPodIP, loadBalancerIP)
+	} else {
+		log.Printf("Reconcile: DRIFT: MASQUERADE rule for %s is missing", remoteGateway)
+	}
+	network.ConfigureSNAT(owner, network.NetActionAdd, remoteGateway, vpnPodIP, loadBalancerIP)
+	return 1
+}
+
+// Ask the kernel how it would route traffic to each remote subnet and log if it would not use the VPN next hop
+func verifyDataPath(routes []routeEntry) {
+	for _, expected := range routes {
+		if isMultipath(expected.routeInfo) {
+			continue
+		}
+		via, dev := routeInfoNextHop(expected.routeInfo)
+		_, networkAddr, err := net.ParseCIDR(expected.subnet)
+		if err != nil {
+			continue
+		}
+		// Use the first host address in the subnet (IPv4 or IPv6) as the probe target
+		probe := networkAddr.IP
+		if ip4 := probe.To4(); ip4 != nil {
+			probe = ip4
+		}
+		if ones, bits := networkAddr.Mask.Size(); bits-ones >= 2 {
+			probe[len(probe)-1]++
+		}
+		route, err := network.GetRouteToAddress(probe.String())
+		if err != nil {
+			log.Printf("Reconcile: ERROR: %v", err)
+			continue
+		}
+		if route.Via != via || route.Dev != dev {
+			log.Printf("Reconcile: DRIFT: traffic to %s is routed via %s dev %s, expected via %s dev %s", probe, route.Via, route.Dev, via, dev)
+		}
+	}
+}
+
+// Locate the route for the specified destination
+func findRoute(routes []network.RoutingInfo, dest string) (network.RoutingInfo, bool) {
+	for _, route := range routes {
+		if route.Dest == dest {
+			return route, true
+		}
+	}
+	return network.RoutingInfo{}, false
+}
+
+// Extract the "via" gateway and "dev" device from route info created by planRouteInfo
+func routeInfoNextHop(routeInfo string) (string, string) {
+	via, dev := "", ""
+	words := strings.Fields(routeInfo)
+	for i, word := range words {
+		if word == "via" && i < len(words)-1 {
+			via = words[i+1]
+		} else if word == "dev" && i < len(words)-1 {
+			dev = words[i+1]
+		}
+	}
+	return via, dev
+}
+
+// Is the route info for a route with multiple next hops (ecmp tunnel group)
+func isMultipath(routeInfo string) bool {
+	return strings.Contains(routeInfo, "nexthop")
+}
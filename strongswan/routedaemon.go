@@ -14,237 +14,478 @@ import (
 	"log"
 	"net"
 	"os"
-	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
 
-	"github.com/IBM-Cloud/iks-strongswan/calico"
+	"github.com/IBM-Cloud/iks-strongswan/cni"
 	"github.com/IBM-Cloud/iks-strongswan/kube"
 	"github.com/IBM-Cloud/iks-strongswan/network"
+	"github.com/IBM-Cloud/iks-strongswan/platform"
 )
 
 // Various constants
 const (
-	envVarNonClusterSubnet = "NON_CLUSTER_SUBNET"
+	envVarDryRun              = "DRY_RUN"
+	envVarNonClusterSubnet    = "NON_CLUSTER_SUBNET"
+	envVarRouteDaemonSelector = "ROUTE_DAEMON_SELECTOR"
+
+	defaultTunnelRouteTable = "199" // Routing table used for the tunl0 routes if the VPN pod did not allocate one
 )
 
+var dryRun bool // Log the route plan instead of changing the routes / rules / nat rules on the node
 var localIP string
 var localSubnet string
+var localZone string // Zone of the worker node, used for zone local routing of tunnel groups
 var nonClusterSubnet string
-var routingTable []network.RoutingInfo // Routing table info for the current node
-var routeTunnel bool                   // Is encapsulation needeed to reach another worker node
-var savedRouteMap map[string]string    // Used by signal handler to clean up added routes
+var routeDaemonSelector string                      // Label selector of the routes config maps when serving multiple releases
+var routingTable []network.RoutingInfo              // Routing table info for the current node
+var routeMutex sync.Mutex                           // Serializes route updates between the config map watcher, reconciler and signal handler
+var savedRouteMaps = map[string]map[string]string{} // Config map data of each release.  Used by signal handler to clean up added routes
+var staleNATRemoved = map[string]bool{}             // Releases whose nat rules from a previous instance of the route daemon were removed
+
+// routeRelease - Route data and settings of a single strongSwan release handled by the route daemon
+type routeRelease struct {
+	key              string // "<namespace>/<config map name>" of the routes config map
+	namespace        string
+	releaseName      string
+	owner            string // "<namespace>.<release name>", tags the nat rules of the release
+	routeData        kube.RouteData
+	localSubnetNAT   string
+	remoteSubnetNAT  string
+	nonClusterSubnet string
+	group            []routeRelease // Releases in the same tunnel group (including this one), preferred tunnel first
+}
+
+// Build the release information for the routes config map.  When a single release is being served, the settings
+// come from the environment variables of the route daemon.  Otherwise they are taken from the config map data
+func newRouteRelease(key string, cmData map[string]string) routeRelease {
+	namespace, name, _ := strings.Cut(key, "/")
+	release := routeRelease{
+		key:              key,
+		namespace:        namespace,
+		releaseName:      strings.TrimSuffix(name, "-strongswan-routes"),
+		routeData:        routeDataOf(cmData),
+		localSubnetNAT:   localSubnetNAT,
+		remoteSubnetNAT:  remoteSubnetNAT,
+		nonClusterSubnet: nonClusterSubnet,
+	}
+	release.owner = release.namespace + "." + release.releaseName
+	if routeDaemonSelector != "" {
+		release.localSubnetNAT = strings.ToLower(release.routeData.LocalSubnetNAT)
+		release.remoteSubnetNAT = strings.ToLower(release.routeData.RemoteSubnetNAT)
+		release.nonClusterSubnet = validateNonClusterSubnet(release.routeData.NonClusterSubnet)
+	}
+	if release.routeData.TunnelGroup != "" {
+		release.group = tunnelGroupMembers(release)
+	}
+	return release
+}
+
+// Collect the releases served by this route daemon that are in the same tunnel group as the release.  The saved
+// config map data is used for the other releases, since the data of the release itself may be changing
+func tunnelGroupMembers(release routeRelease) []routeRelease {
+	group := []routeRelease{release}
+	for key, cmData := range savedRouteMaps {
+		if key != release.key && routeDataOf(cmData).TunnelGroup == release.routeData.TunnelGroup {
+			group = append(group, routeRelease{key: key, routeData: routeDataOf(cmData)})
+		}
+	}
+	sort.Slice(group, func(i, j int) bool {
+		pi, _ := strconv.Atoi(group[i].routeData.TunnelPriority) // #nosec G104 validated by the VPN pod, 0 if not set
+		pj, _ := strconv.Atoi(group[j].routeData.TunnelPriority) // #nosec G104 validated by the VPN pod, 0 if not set
+		if pi != pj {
+			return pi < pj
+		}
+		return group[i].key < group[j].key
+	})
+	return group
+}
+
+// Plan the current state of the other releases in the tunnel groups.  Called before the saved config map data of
+// the release changes, so the other releases can be updated by applyGroupPeers once the change has been made
+func planGroupPeers(key string, tunnelGroups ...string) map[string]routeState {
+	peerStates := map[string]routeState{}
+	for peerKey, cmData := range savedRouteMaps {
+		if peerKey == key {
+			continue
+		}
+		for _, tunnelGroup := range tunnelGroups {
+			if tunnelGroup != "" && routeDataOf(cmData).TunnelGroup == tunnelGroup {
+				peerStates[peerKey] = appliedRouteState(newRouteRelease(peerKey, cmData))
+			}
+		}
+	}
+	return peerStates
+}
+
+// Update the routes of the other releases in the tunnel group after one of the tunnels changed
+func applyGroupPeers(peerStates map[string]routeState) {
+	for _, peerKey := range sortedKeys(peerStates) {
+		cmData, ok := savedRouteMaps[peerKey]
+		if !ok {
+			continue
+		}
+		peer := newRouteRelease(peerKey, cmData)
+		newState := planRouteState(peer, currentNode(peer))
+		recordRouteState(peerKey, newState)
+		plan := planRouteChanges(peer.owner, peerStates[peerKey], newState, false)
+		if len(plan) == 0 {
+			continue
+		}
+		log.Printf("Updating the routes of %s in tunnel group %s", peerKey, peer.routeData.TunnelGroup)
+		executeRoutePlan(plan)
+		publishRouteStatus(peer)
+	}
+}
+
+// Extract the routing data of a routes config map.  The data of the config maps that are applied was accepted by
+// acceptRouteData, so an error is only logged
+func routeDataOf(cmData map[string]string) kube.RouteData {
+	routeData, err := kube.MapToRouteData(cmData)
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+	}
+	return routeData
+}
+
+// Verify that the route data of the config map can be used.  If it can not, the routes of the release are not
+// changed and the error is published in the status of this node
+func acceptRouteData(key string, cmData map[string]string) bool {
+	if _, err := kube.MapToRouteData(cmData); err != nil {
+		log.Printf("ERROR: ConfigMap %s is not applied: %v", key, err)
+		namespace, name, _ := strings.Cut(key, "/")
+		release := routeRelease{key: key, namespace: namespace, releaseName: strings.TrimSuffix(name, "-strongswan-routes")}
+		publishRouteError(release, kube.RouteDataGeneration(cmData), err)
+		return false
+	}
+	return true
+}
+
+// Key used to track the saved data of the routes config map
+func configMapKey(cm *corev1.ConfigMap) string {
+	return cm.Namespace + "/" + cm.Name
+}
 
 // Configmap was created.  Add the necessary routes
 func configMapCreated(obj interface{}) {
+	routeMutex.Lock()
+	defer routeMutex.Unlock()
 	cm := obj.(*corev1.ConfigMap)
-	log.Printf("ConfigMap created: %v", kube.MapToSortedString(cm.Data))
-	handleRoutes(cm.Data, network.NetActionAdd)
-	savedRouteMap = cm.Data
+	key := configMapKey(cm)
+	log.Printf("ConfigMap %s created: %v", key, kube.MapToSortedString(cm.Data))
+	if !acceptRouteData(key, cm.Data) {
+		return
+	}
+	release := newRouteRelease(key, cm.Data)
+	peerStates := planGroupPeers(key, release.routeData.TunnelGroup)
+	if recovered, ok := recoveredRouteMaps[key]; ok {
+		log.Printf("ConfigMap %s (recovered): %v", key, kube.RouteDataString(recovered))
+		delete(recoveredRouteMaps, key)
+		recoverRoutes(newRouteRelease(key, recovered), release)
+	} else {
+		handleRoutes(release, network.NetActionAdd)
+	}
+	savedRouteMaps[key] = cm.Data
+	applyGroupPeers(peerStates)
+	saveRouteState()
+	publishRouteStatus(release)
 }
 
 // Configmap was deleted.  Delete the old routes
 func configMapDeleted(obj interface{}) {
+	routeMutex.Lock()
+	defer routeMutex.Unlock()
 	cm := obj.(*corev1.ConfigMap)
-	log.Printf("ConfigMap deleted: %v", kube.MapToSortedString(cm.Data))
-	savedRouteMap = nil
-	handleRoutes(cm.Data, network.NetActionDelete)
+	key := configMapKey(cm)
+	log.Printf("ConfigMap %s deleted: %v", key, kube.MapToSortedString(cm.Data))
+	// The routes that were applied are removed, the data of the deleted config map may not have been accepted
+	cmData, ok := savedRouteMaps[key]
+	if !ok {
+		cmData = cm.Data
+	}
+	peerStates := planGroupPeers(key, routeDataOf(cmData).TunnelGroup)
+	delete(savedRouteMaps, key)
+	// Another tunnel of the group takes over the routes before the routes of this release are removed
+	applyGroupPeers(peerStates)
+	release := newRouteRelease(key, cmData)
+	handleRoutes(release, network.NetActionDelete)
+	saveRouteState()
+	removeRouteStatus(release)
 }
 
-// Configmap was updated.  Delete the old routes / Add the new ones.
+// Configmap was updated.  Apply the differences between the old and the new routes
 func configMapUpdated(oldObj, newObj interface{}) {
+	routeMutex.Lock()
+	defer routeMutex.Unlock()
 	oldCm := oldObj.(*corev1.ConfigMap)
 	newCm := newObj.(*corev1.ConfigMap)
-	if savedRouteMap == nil {
-		log.Print("ConfigMap updated - no saved data") // should never occur
+	key := configMapKey(newCm)
+	if !acceptRouteData(key, newCm.Data) {
+		return
+	}
+	savedRouteMap, ok := savedRouteMaps[key]
+	if !ok {
+		log.Printf("ConfigMap %s updated - no saved data", key) // should never occur if the old data was accepted
 		savedRouteMap = oldCm.Data
 	}
-	savedData := kube.MapToSortedString(savedRouteMap)
-	oldData := kube.MapToSortedString(oldCm.Data)
-	newData := kube.MapToSortedString(newCm.Data)
+	savedData := kube.RouteDataString(savedRouteMap)
+	oldData := kube.RouteDataString(oldCm.Data)
+	newData := kube.RouteDataString(newCm.Data)
 	if savedData == newData {
-		log.Printf("ConfigMap updated - no change in saved data: %v", newData)
+		log.Printf("ConfigMap %s updated - no change in saved data: %v", key, newData)
 		log.Printf("   old object: %v", oldCm)
 		log.Printf("   new object: %v", newCm)
+		// The generation may have changed, so acknowledge the new one
+		savedRouteMaps[key] = newCm.Data
+		publishRouteStatus(newRouteRelease(key, newCm.Data))
 		return
 	}
 	if savedData != oldData {
-		log.Print("ConfigMap updated - saved data does not match old data:") // should never occur
+		log.Printf("ConfigMap %s updated - saved data does not match old data:", key) // should never occur
 		log.Printf("   saved data: %v", savedData)
 		log.Printf("   old data:   %v", oldData)
 	}
-	log.Printf("ConfigMap updated (old): %v", savedData)
-	handleRoutes(savedRouteMap, network.NetActionDelete)
-	savedRouteMap = nil
+	log.Printf("ConfigMap %s updated (old): %v", key, savedData)
+	log.Printf("ConfigMap %s updated (new): %v", key, newData)
+	release := newRouteRelease(key, newCm.Data)
+	oldRelease := newRouteRelease(key, savedRouteMap)
+	peerStates := planGroupPeers(key, oldRelease.routeData.TunnelGroup, release.routeData.TunnelGroup)
+	savedRouteMaps[key] = newCm.Data
+	// If another tunnel of the group takes over the routes, it is updated before the routes of this release are removed
+	applyGroupPeers(peerStates)
+	updateRoutes(oldRelease, release)
+	saveRouteState()
+	publishRouteStatus(release)
+}
 
-	log.Printf("ConfigMap updated (new): %v", newData)
-	handleRoutes(newCm.Data, network.NetActionAdd)
-	savedRouteMap = newCm.Data
+// Remove the routes of all of the releases.  Called by the signal handler
+func deleteAllRoutes() {
+	routeMutex.Lock()
+	defer routeMutex.Unlock()
+	for key, cmData := range savedRouteMaps {
+		release := newRouteRelease(key, cmData)
+		handleRoutes(release, network.NetActionDelete)
+		removeRouteStatus(release)
+	}
+	for key, cmData := range recoveredRouteMaps {
+		handleRoutes(newRouteRelease(key, cmData), network.NetActionDelete)
+	}
+	savedRouteMaps = map[string]map[string]string{} // Stop the reconciler from restoring the routes
+	recoveredRouteMaps = map[string]map[string]string{}
+	saveRouteState()
 }
 
 // Handle config map route data.  Single routine will do either ADD or DELETE
-func handleRoutes(cmData map[string]string, addDelAction network.NetAddDelAction) {
-	routeData := kube.MapToRouteData(cmData)
+func handleRoutes(release routeRelease, addDelAction network.NetAddDelAction) {
+	routeData := release.routeData
 	if routeData.RemoteSubnet == "" || routeData.RouteTable == "" || routeData.WorkerNodeIP == "" || routeData.WorkerSubnet == "" {
 		return
 	}
-
-	// Create the list of remote subnets with NAT applied so it can be passed to the routing functions that need it
-	remappedRemoteSubnet := routeData.RemoteSubnet
-	if remoteSubnetNAT != "" {
-		for _, rule := range strings.Split(remoteSubnetNAT, ",") {
-			orig := strings.Split(rule, "=")[0]
-			mapped := strings.Split(rule, "=")[1]
-			remappedRemoteSubnet = strings.ReplaceAll(remappedRemoteSubnet, orig, mapped)
-		}
-		log.Printf(" - remapped remote subnets based on remoteSubnetNAT: %s", remappedRemoteSubnet)
-	}
-
 	log.Printf("Attempting to %s routes/rules", addDelAction)
+	flushNAT := false
 	if addDelAction == network.NetActionAdd {
-		if remoteSubnetNAT == "" {
-			validateIPNotInRemoteSubnet(localIP, routeData.RemoteSubnet)
-			validateRoutesForRemoteSubnet(routeData.RemoteSubnet)
-		} else {
-			validateIPNotInRemoteSubnet(localIP, remappedRemoteSubnet)
-			validateRoutesForRemoteSubnet(remappedRemoteSubnet)
-		}
+		flushNAT = prepareRelease(release)
+	} else {
+		logRelease(release)
+	}
+	node := currentNode(release)
+	var plan []routeOperation
+	if addDelAction == network.NetActionAdd {
+		plan = planRoutes(release, node, addDelAction, flushNAT)
+		recordRouteState(release.key, planRouteState(release, node))
+	} else {
+		// The state that was applied is removed, since the pods on the node may have changed after it was applied
+		plan = append(planRouteChanges(release.owner, appliedRouteState(release), newRouteState(), flushNAT), planConntrack(routeData)...)
+		recordRouteState(release.key, newRouteState())
+	}
+	executeRoutePlan(plan)
+	listReleaseRoutes(release)
+}
+
+// Config map data was updated.  Only the routes, rules and SNAT rules that differ between the old and the new data
+// are changed, so traffic to the remote subnets that did not change is not interrupted
+func updateRoutes(oldRelease, newRelease routeRelease) {
+	log.Print("Attempting to update routes/rules")
+	oldState := appliedRouteState(oldRelease)
+	flushNAT := false
+	routeData := newRelease.routeData
+	if routeData.RemoteSubnet != "" && routeData.RouteTable != "" && routeData.WorkerNodeIP != "" && routeData.WorkerSubnet != "" {
+		flushNAT = prepareRelease(newRelease)
+	}
+	newState := planRouteState(newRelease, currentNode(newRelease))
+	recordRouteState(newRelease.key, newState)
+	plan := planRouteChanges(newRelease.owner, oldState, newState, flushNAT)
+	if len(plan) == 0 {
+		return
+	}
+	if snatChanged(oldState, newState) {
+		plan = append(plan, planConntrack(routeData)...)
 	}
+	executeRoutePlan(plan)
+	listReleaseRoutes(newRelease)
+}
 
-	deviceName := ""
-	if localIP != routeData.WorkerNodeIP {
-		deviceName = network.GetDeviceToWorkerNode(routeData.WorkerNodeIP)
+// Config map data was applied by a previous route daemon.  The routes, rules and SNAT rules may no longer exist on
+// the node (ex: the node was rebooted), so all of them are applied again.  Entries that already exist are not changed.
+// The state applied by the previous route daemon is only used to remove the entries that are no longer needed, such
+// as the rules of pods that were deleted while the route daemon was not running
+func recoverRoutes(oldRelease, newRelease routeRelease) {
+	log.Print("Attempting to recover routes/rules")
+	oldState := appliedRouteState(oldRelease)
+	flushNAT := false
+	routeData := newRelease.routeData
+	if routeData.RemoteSubnet != "" && routeData.RouteTable != "" && routeData.WorkerNodeIP != "" && routeData.WorkerSubnet != "" {
+		flushNAT = prepareRelease(newRelease)
 	}
+	newState := planRouteState(newRelease, currentNode(newRelease))
+	recordRouteState(newRelease.key, newState)
+	plan := planRouteChanges(newRelease.owner, oldState.staleEntries(newState), newState, flushNAT)
+	executeRoutePlan(append(plan, planConntrack(routeData)...))
+	listReleaseRoutes(newRelease)
+}
 
-	routeInfo := ""
+// Log how the release is handled on this node
+func logRelease(release routeRelease) {
+	routeData := release.routeData
+	if release.remoteSubnetNAT != "" {
+		log.Printf(" - remapped remote subnets based on remoteSubnetNAT: %s", release.remapRemoteSubnet())
+	}
 	if localIP == routeData.WorkerNodeIP {
 		log.Printf(" - same worker node as the VPN pod: %s", localIP)
-		// If there are tunnels in the routing table, we may need to tunnel traffic from on-prem to diff subnet
-		if routeTunnel {
-			handleRoutesVpnNode(addDelAction, routeData.LocalSubnet, remappedRemoteSubnet)
-		}
 		if routeData.WorkerNodeIP == routeData.VpnPodIP {
 			log.Print("VPN pod is using host networking.  Additional route is not needed")
-			return
-		}
-		routeInfo = "via " + routeData.VpnPodIP + " dev " + routeData.VpnPodDevice + " table " + routeData.RouteTable
-
-		// Add route for nonCluster subnets if requested
-		if nonClusterSubnet != "" {
-			handleRoutesNonCluster(addDelAction, routeData, nonClusterSubnet)
 		}
+	} else if localSubnet == routeData.WorkerSubnet {
+		log.Printf(" - same subnet as the VPN pod worker node: %s", localSubnet)
 	} else {
-		if localSubnet == routeData.WorkerSubnet {
-			log.Printf(" - same subnet as the VPN pod worker node: %s", localSubnet)
-		} else {
-			log.Printf(" - different subnet than the VPN pod worker node: %s != %s", localSubnet, routeData.WorkerSubnet)
-		}
+		log.Printf(" - different subnet than the VPN pod worker node: %s != %s", localSubnet, routeData.WorkerSubnet)
+	}
+}
 
-		if localSubnet != routeData.WorkerSubnet || deviceName == "tunl0" {
-			routeInfo = "via " + routeData.WorkerNodeIP + " dev " + deviceName + " onlink table " + routeData.RouteTable
-		} else {
-			routeInfo = "via " + routeData.WorkerNodeIP + " dev " + deviceName + " table " + routeData.RouteTable
-		}
+// Validate the release before its routes are added.  Returns true the first time a release is seen, so any nat
+// rules left behind by a previous instance of the route daemon are removed
+func prepareRelease(release routeRelease) bool {
+	flushNAT := !staleNATRemoved[release.owner]
+	staleNATRemoved[release.owner] = true
+	logRelease(release)
+	remappedRemoteSubnet := release.remapRemoteSubnet()
+	validateIPNotInRemoteSubnet(localIP, remappedRemoteSubnet)
+	validateRoutesForRemoteSubnet(remappedRemoteSubnet)
+	if localIP == release.routeData.WorkerNodeIP && release.nonClusterSubnet != "" {
+		validateNonClusterRelease(release)
 	}
+	return flushNAT
+}
 
-	// Update routes / rules and list them out
-	network.RouteRemoteSubnet(addDelAction, remappedRemoteSubnet, routeInfo)
-	network.UpdateRouteRule(addDelAction, "all", routeData.RouteTable)
+// List the rules and the routing tables of the release
+func listReleaseRoutes(release routeRelease) {
 	network.ListRules()
-	network.ListRoutes(routeData.RouteTable)
-	if localIP == routeData.WorkerNodeIP { // VPN worker node
-		network.ListRoutes("199")
-	}
-	if routeData.ConnectUsingLB == "true" {
-		handleRoutesSNAT(addDelAction, routeData)
+	network.ListRoutes(release.routeData.RouteTable)
+	if localIP == release.routeData.WorkerNodeIP { // VPN worker node
+		network.ListRoutes(tunnelRouteTable(release.routeData))
 	}
-	// If we have a load balancer IP, delete any stale conntrack entry from the remote gateway to the load balancer IP
-	if net.ParseIP(routeData.LoadBalancerIP) != nil && net.ParseIP(routeData.RemoteGateway) != nil && runtime.GOOS != "darwin" {
-		network.DeleteConntrackEntry(routeData.RemoteGateway, routeData.LoadBalancerIP)
+}
+
+// Routing table used for the tunl0 routes on the VPN worker node.  Older VPN pods did not record one in the config map
+func tunnelRouteTable(routeData kube.RouteData) string {
+	if routeData.TunnelTable == "" {
+		return defaultTunnelRouteTable
 	}
+	return routeData.TunnelTable
 }
 
-// If localNonClusterSubnet was specified in the config map, need to configure iptable rules
-func handleRoutesNonCluster(addDelAction network.NetAddDelAction, routeData kube.RouteData, nonClusterSubnet string) {
-	// Since calico is configured to not NAT to the local no cluster sunbets, we need to add this rule which masquerades the traffic
-	for _, subnet := range strings.Split(nonClusterSubnet, ",") {
-		if routeData.WorkerSubnet == subnet {
-			log.Printf("WARNING: localNonClusterSubnet specified %s contains the worker node subnet %s", nonClusterSubnet, subnet)
+// Collect the information about this worker node that is needed to plan the routes of the release
+func currentNode(release routeRelease) nodeInfo {
+	node := nodeInfo{ip: localIP, subnet: localSubnet, routingTable: routingTable, workerNodeDevices: map[string]string{}, vpnPodDevices: map[string]string{},
+		overlayDevice: cni.Get().OverlayDevice(), pods: sortedNodePods(), zone: localZone}
+	for _, member := range append([]routeRelease{release}, release.group...) {
+		if member.routeData.WorkerNodeIP == localIP && member.routeData.VpnPodDevice == "" && member.routeData.VpnPodIP != member.routeData.WorkerNodeIP {
+			// The host side interface of the VPN pod is not known by all pod networks, use the route to the VPN pod
+			for _, vpnPodIP := range []string{member.routeData.VpnPodIP, member.routeData.VpnPodIPv6} {
+				if vpnPodIP != "" && node.vpnPodDevices[vpnPodIP] == "" {
+					node.vpnPodDevices[vpnPodIP] = deviceToAddress(vpnPodIP)
+				}
+			}
+		}
+		if member.routeData.WorkerNodeIP == "" || member.routeData.WorkerNodeIP == localIP {
 			continue
 		}
-
-		if !strings.Contains(routeData.LocalSubnet, subnet) {
-			// If the localNonClusterSubnet is not explicitly configured as a local subnet then show a warning but still configure the NAT
-			log.Printf("WARNING: localNonClusterSubnet specified but is not configured as a local subnet %v", subnet)
+		for _, workerNodeIP := range []string{member.routeData.WorkerNodeIP, member.routeData.WorkerNodeIPv6} {
+			if workerNodeIP != "" && node.workerNodeDevices[workerNodeIP] == "" {
+				node.workerNodeDevices[workerNodeIP] = deviceToAddress(workerNodeIP)
+			}
 		}
-		network.ConfigureSNAT(addDelAction, subnet, "", "")
 	}
+	return node
 }
 
-// If connectUsingLB was specified in the config map, need to configure iptable rules
-func handleRoutesSNAT(addDelAction network.NetAddDelAction, routeData kube.RouteData) {
-	if localIP == routeData.WorkerNodeIP {
-		// Special SNAT rules are needed on the worker node where the VPN pod is running
-		network.ConfigureSNAT(addDelAction, routeData.RemoteGateway, routeData.VpnPodIP, routeData.LoadBalancerIP)
+// Determine the device used to reach the VPN pod or worker node.  An empty device is recorded if there is no route
+// to it yet, so the routes of the release are not planned and the failure is published in the status of the node
+func deviceToAddress(ip string) string {
+	device, err := network.GetDeviceToWorkerNode(ip)
+	if err != nil {
+		log.Printf("WARNING: %v", err)
 	}
-	// Since calico is configured to not NAT to the remote gateway, we need to add this rule on all worker nodes
-	network.ConfigureSNAT(addDelAction, routeData.RemoteGateway, "", "")
+	return device
+}
 
-	// Finally, if this was as "ADD", we need to incoming the vpnPod that the SNAT rules are in place
-	if addDelAction == network.NetActionAdd && localIP == routeData.WorkerNodeIP {
-		log.Printf("SNAT rules have been added.  Wake up the vpnPod located here: %v", routeData.VpnPodIP+":4500")
-		conn, err := net.Dial("tcp", routeData.VpnPodIP+":4500")
-		if err != nil {
-			log.Printf("ERROR: Failed to connect to the VPN pod: %v", err)
+// List the VPN pods and worker nodes of the release that the node has no route to
+func (node nodeInfo) unreachableAddresses() []string {
+	problems := []string{}
+	for _, ip := range sortedKeys(node.vpnPodDevices) {
+		if node.vpnPodDevices[ip] == "" {
+			problems = append(problems, "no route to VPN pod "+ip)
 		}
-		if conn != nil {
-			conn.Close() // #nosec G104 ok to ignore error on close
+	}
+	for _, ip := range sortedKeys(node.workerNodeDevices) {
+		if node.workerNodeDevices[ip] == "" {
+			problems = append(problems, "no route to VPN worker node "+ip)
 		}
 	}
+	return problems
 }
 
-// If tunnels have been defined, additional routes are needed to handle cross-subnet traffic
-func handleRoutesVpnNode(addDelAction network.NetAddDelAction, localSubnetList, remoteSubnetList string) {
-	ruleNeeded := false
-	for _, localSub := range strings.Split(localSubnetList, ",") { // For each local subnet shared
-		if localSub == localSubnet { // If current subnet, no tunnel needed
-			continue
-		}
-		if network.TunnelNeededToReachSubnet(localSub, routingTable) {
-			network.UpdateRoute(addDelAction, localSub, "dev tunl0 table 199")
-			ruleNeeded = true
-		}
+// Apply the remoteSubnetNAT rules of the release to the list of remote subnets
+func (release routeRelease) remapRemoteSubnet() string {
+	if release.remoteSubnetNAT == "" {
+		return release.routeData.RemoteSubnet
 	}
-	// With the introduction of local subnet NAT, we now need
-	// to examine the inside/internal local subnets too
-	if localSubnetNAT != "" {
-		for _, local := range strings.Split(localSubnetNAT, ",") {
-			localSub := strings.Split(local, "=")[0] // Only look at inside network of the NAT
-			if localSub == localSubnet {             // If current subnet, no tunnel needed
-				continue
-			}
-			if network.TunnelNeededToReachSubnet(localSub, routingTable) {
-				network.UpdateRoute(addDelAction, localSub, "dev tunl0 table 199")
-				ruleNeeded = true
-			}
-		}
+	remappedRemoteSubnet := release.routeData.RemoteSubnet
+	for _, rule := range strings.Split(release.remoteSubnetNAT, ",") {
+		orig := strings.Split(rule, "=")[0]
+		mapped := strings.Split(rule, "=")[1]
+		remappedRemoteSubnet = strings.ReplaceAll(remappedRemoteSubnet, orig, mapped)
 	}
-	if ruleNeeded {
-		for _, remoteSub := range strings.Split(remoteSubnetList, ",") { // For each local subnet shared
-			network.UpdateRouteRule(addDelAction, remoteSub, "199") // Always use route table 199 for these tunnel routes
+	return remappedRemoteSubnet
+}
+
+// Warn about localNonClusterSubnet values that will not work as expected.  Since calico is configured to not NAT
+// to the local non cluster subnets, a rule is added which masquerades the traffic (see planRouteState)
+func validateNonClusterRelease(release routeRelease) {
+	routeData := release.routeData
+	nonClusterSubnet := release.nonClusterSubnet
+	for _, subnet := range strings.Split(nonClusterSubnet, ",") {
+		if routeData.WorkerSubnet == subnet {
+			log.Printf("WARNING: localNonClusterSubnet specified %s contains the worker node subnet %s", nonClusterSubnet, subnet)
+			continue
+		}
+
+		if !strings.Contains(routeData.LocalSubnet, subnet) {
+			// If the localNonClusterSubnet is not explicitly configured as a local subnet then show a warning but still configure the NAT
+			log.Printf("WARNING: localNonClusterSubnet specified but is not configured as a local subnet %v", subnet)
 		}
 	}
 }
 
 // Perform any initialization needed by the route daemon
-func routeDaemonInit() {
+func routeDaemonInit(kubectl *kubernetes.Clientset) {
 	routingTable = network.GetRoutingTable()
-	for _, route := range routingTable {
-		if route.Dev == "tunl0" {
-			routeTunnel = true
-		}
-	}
 
 	// Get the pod IP = worker node private IP
 	localIP = os.Getenv(envVarPodIP)
@@ -254,20 +495,73 @@ func routeDaemonInit() {
 	log.Printf("local IP: %v", localIP)
 
 	// Determine the local subnet for the current node
-	localSubnet = calico.GetNodeSubnet(localIP)
+	subnet, err := cni.Get().NodeSubnet(localIP)
+	if err != nil {
+		log.Fatalf("ERROR: Failed to retrieve the subnet of the worker node: %v", err)
+	}
+	localSubnet = subnet
 	log.Printf("local subnet: %v", localSubnet)
 
+	// Determine how often the routes on the node are reconciled
+	initReconcileInterval()
+
+	// In dry run mode, the planned changes are logged but not made
+	if strings.ToLower(os.Getenv(envVarDryRun)) == "true" {
+		dryRun = true
+		log.Print("DRY RUN: Route, rule and nat changes will only be logged")
+	}
+
+	// Load the routes applied by a previous instance of the route daemon, so they can be reconciled
+	loadRouteState()
+
+	// Acknowledgements of the applied routes are published using the name of the worker node
+	routeDaemonClient = kubectl
+	nodeName = os.Getenv(envVarNodeName)
+	if nodeName == "" {
+		log.Printf("WARNING: Environment variable %s was not specified.  Route status will not be published", envVarNodeName)
+	} else if kubectl != nil {
+		// Zone local routing of tunnel groups prefers the VPN pod in the zone of this worker node
+		initPlatform(kubectl, nodeName, localIP)
+		zone, err := platform.Get().NodeZone(kubectl, nodeName, localIP)
+		if err != nil {
+			log.Printf("WARNING: Unable to determine the zone of the worker node.  Zone local routing is disabled: %v", err)
+		} else {
+			localZone = zone
+			log.Printf("local zone: %v", localZone)
+		}
+	}
+
 	// Check to see if non-cluster subnet was configured
-	nonClusterSubnet = os.Getenv(envVarNonClusterSubnet)
-	if nonClusterSubnet != "" {
-		for _, subnet := range strings.Split(nonClusterSubnet, ",") {
-			_, _, err := net.ParseCIDR(subnet)
-			if err != nil {
-				log.Printf("WARNING: local non cluster subnet %v is not a valid subnet", subnet)
-				nonClusterSubnet = ""
-			}
+	nonClusterSubnet = validateNonClusterSubnet(os.Getenv(envVarNonClusterSubnet))
+
+	// Check to see if this route daemon serves all of the releases that match a label selector
+	routeDaemonSelector = os.Getenv(envVarRouteDaemonSelector)
+	if routeDaemonSelector != "" {
+		if _, err := labels.Parse(routeDaemonSelector); err != nil {
+			log.Fatalf("ERROR: Invalid value specified for %s: %v", envVarRouteDaemonSelector, err)
+		}
+		log.Printf("Serving the routes config maps in all namespaces that match: %s", routeDaemonSelector)
+	}
+	// The tunnels of a group are only routed together by a route daemon that serves all of the releases of the group.
+	// A route daemon that only serves its own release would never fail over or spread the traffic
+	if tunnelGroup := os.Getenv(envVarTunnelGroup); tunnelGroup != "" && routeDaemonSelector == "" {
+		log.Fatalf("ERROR: Tunnel group %s requires a shared route daemon.  %s must be set", tunnelGroup, envVarRouteDaemonSelector)
+	}
+}
+
+// Verify the list of local non cluster subnets.  Empty string is returned if any of the subnets is not valid
+func validateNonClusterSubnet(subnetList string) string {
+	if subnetList == "" {
+		return ""
+	}
+	for _, subnet := range strings.Split(subnetList, ",") {
+		_, _, err := net.ParseCIDR(subnet)
+		if err != nil {
+			log.Printf("WARNING: local non cluster subnet %v is not a valid subnet", subnet)
+			return ""
 		}
 	}
+	return subnetList
 }
 
 // Validate the remote routes requested vs local routes on the node
@@ -0,0 +1,134 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/IBM-Cloud/iks-strongswan/kube"
+	"github.com/IBM-Cloud/iks-strongswan/network"
+)
+
+// Various constants
+const (
+	routeStateDir     = "/var/lib/strongswan" // hostPath directory on the worker node, survives restarts of the route daemon
+	routeStateTmpFile = "/tmp/routeState.json"
+)
+
+var routeStateFile string                               // File holding the config map data and route state that were applied on this node
+var recoveredRouteMaps = map[string]map[string]string{} // Config map data applied by a previous instance of the route daemon, not yet reconciled
+var appliedRouteStates = map[string]routeState{}        // Routes, rules and SNAT rules applied on this node for each release
+
+// routeStateData - Contents of the route state file
+type routeStateData struct {
+	ConfigMaps map[string]map[string]string `json:"configMaps"` // Config map data of each release
+	States     map[string]routeState        `json:"states"`     // Applied route state of each release, including the pod rules
+}
+
+// Load the config map data and the route state that a previous instance of the route daemon applied on this node
+func loadRouteState() {
+	routeStateFile = filepath.Join(routeStateDir, namespace+"."+releaseName+".json")
+	data, err := os.ReadFile(routeStateFile) // #nosec G304 file name is built from fixed constants and the release name
+	if os.IsNotExist(err) {
+		log.Printf("No route state from a previous route daemon: %s", routeStateFile)
+		return
+	}
+	if err != nil {
+		log.Printf("WARNING: Unable to read route state %s: %v", routeStateFile, err)
+		return
+	}
+	stateData := routeStateData{}
+	if err := json.Unmarshal(data, &stateData); err != nil {
+		log.Printf("WARNING: Ignoring invalid route state %s: %v", routeStateFile, err)
+		return
+	}
+	if stateData.ConfigMaps != nil {
+		recoveredRouteMaps = stateData.ConfigMaps
+	}
+	if stateData.States != nil {
+		appliedRouteStates = stateData.States
+	}
+	for key, cmData := range recoveredRouteMaps {
+		log.Printf("Recovered route state for %s: %v", key, kube.MapToSortedString(cmData))
+	}
+}
+
+// Persist the config map data and the route state that are applied on this node.  Recovered data that has not been
+// reconciled yet is kept, so it is not lost if the route daemon is restarted again before the config maps are listed
+func saveRouteState() {
+	if routeStateFile == "" || dryRun {
+		return
+	}
+	stateData := routeStateData{ConfigMaps: map[string]map[string]string{}, States: appliedRouteStates}
+	for key, cmData := range recoveredRouteMaps {
+		stateData.ConfigMaps[key] = cmData
+	}
+	for key, cmData := range savedRouteMaps {
+		stateData.ConfigMaps[key] = cmData
+	}
+	data, err := json.Marshal(stateData)
+	if err != nil {
+		log.Printf("WARNING: Unable to encode route state: %v", err)
+		return
+	}
+	if err := os.WriteFile(routeStateTmpFile, data, 0600); err != nil {
+		log.Printf("WARNING: Unable to write route state: %v", err)
+		return
+	}
+	// The hostPath directory is owned by root
+	outBytes, err := exec.Command("sudo", "/bin/cp", routeStateTmpFile, routeStateFile).CombinedOutput() // #nosec G204 file names are built from fixed constants and the release name
+	if err != nil {
+		log.Printf("WARNING: Unable to save route state %s: %v - %s", routeStateFile, err, strings.TrimSpace(string(outBytes)))
+	}
+}
+
+// Routes, rules and SNAT rules that were last applied for the release, by this or a previous route daemon.  If they
+// are not known, they are planned from the config map data of the release
+func appliedRouteState(release routeRelease) routeState {
+	if state, ok := appliedRouteStates[release.key]; ok {
+		return state
+	}
+	return planRouteState(release, currentNode(release))
+}
+
+// Record the routes, rules and SNAT rules applied for the release.  The pod rules depend on the pods running on the
+// node, so the state is saved for the next route daemon instead of being planned again from the config map data
+func recordRouteState(key string, state routeState) {
+	if state.empty() {
+		delete(appliedRouteStates, key)
+		return
+	}
+	appliedRouteStates[key] = state
+}
+
+// Once the initial list of config maps has been processed, remove the routes of any recovered config map that no
+// longer exists.  Recovered config maps that still exist were reconciled by configMapCreated
+func removeStaleRoutes(synced func() bool) {
+	for !synced() {
+		time.Sleep(time.Second)
+	}
+	routeMutex.Lock()
+	defer routeMutex.Unlock()
+	for key, cmData := range recoveredRouteMaps {
+		log.Printf("ConfigMap %s no longer exists.  Removing the routes applied by a previous route daemon", key)
+		release := newRouteRelease(key, cmData)
+		handleRoutes(release, network.NetActionDelete)
+		executeRoutePlan([]routeOperation{{kind: opNATFlush, action: network.NetActionDelete, owner: release.owner}})
+	}
+	recoveredRouteMaps = map[string]map[string]string{}
+	saveRouteState()
+}
@@ -0,0 +1,216 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/IBM-Cloud/iks-strongswan/network"
+)
+
+// routeEntry - Route that the release needs in one of the routing tables of this node
+type routeEntry struct {
+	subnet     string
+	routeTable string
+	routeInfo  string // "via ... dev ... table ..."
+}
+
+// ruleEntry - Rule that sends traffic from the source to one of the routing tables of the release
+type ruleEntry struct {
+	fromSource string
+	routeTable string
+	priority   string
+}
+
+// snatEntry - SNAT / MASQUERADE rule created by network.ConfigureSNAT
+type snatEntry struct {
+	remoteGateway  string
+	vpnPodIP       string
+	loadBalancerIP string
+}
+
+// routeState - Routes, rules and SNAT rules that a release needs on this node
+type routeState struct {
+	routes map[string]routeEntry // Keyed by "<table> <subnet>"
+	rules  map[string]ruleEntry  // Keyed by "<source> <table>", the priority is not part of the identity of an ip rule
+	snat   map[string]snatEntry  // Keyed by "<remote gateway> <vpn pod IP> <load balancer IP>"
+}
+
+// Create an empty route state
+func newRouteState() routeState {
+	return routeState{routes: map[string]routeEntry{}, rules: map[string]ruleEntry{}, snat: map[string]snatEntry{}}
+}
+
+func (state routeState) addRoute(subnet, routeTable, routeInfo string) {
+	state.routes[routeTable+" "+subnet] = routeEntry{subnet: subnet, routeTable: routeTable, routeInfo: routeInfo}
+}
+
+// List the routing tables used by the routes of the state
+func (state routeState) routeTables() []string {
+	tables := map[string]bool{}
+	for _, route := range state.routes {
+		tables[route.routeTable] = true
+	}
+	return sortedKeys(tables)
+}
+
+// List the routes of the state that are in the routing table
+func (state routeState) tableRoutes(routeTable string) []routeEntry {
+	routes := []routeEntry{}
+	for _, key := range sortedKeys(state.routes) {
+		if route := state.routes[key]; route.routeTable == routeTable {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+func (state routeState) addRule(fromSource, routeTable, priority string) {
+	if priority == "" {
+		priority = routeTable
+	}
+	fromSource = strings.TrimSuffix(strings.TrimSuffix(fromSource, "/32"), "/128")
+	state.rules[fromSource+" "+routeTable] = ruleEntry{fromSource: fromSource, routeTable: routeTable, priority: priority}
+}
+
+func (state routeState) addSNAT(remoteGateway, vpnPodIP, loadBalancerIP string) {
+	state.snat[remoteGateway+" "+vpnPodIP+" "+loadBalancerIP] = snatEntry{remoteGateway: remoteGateway, vpnPodIP: vpnPodIP, loadBalancerIP: loadBalancerIP}
+}
+
+// routeStateJSON - Format of the route state in the route state file
+type routeStateJSON struct {
+	Routes []string `json:"routes,omitempty"` // "<table> <subnet> <route info>"
+	Rules  []string `json:"rules,omitempty"`  // "<source> <table> <priority>"
+	SNAT   []string `json:"snat,omitempty"`   // "<remote gateway> <vpn pod IP> <load balancer IP>", the IPs may be empty
+}
+
+// MarshalJSON - Encode the route state for the route state file
+func (state routeState) MarshalJSON() ([]byte, error) {
+	data := routeStateJSON{}
+	for _, key := range sortedKeys(state.routes) {
+		route := state.routes[key]
+		data.Routes = append(data.Routes, route.routeTable+" "+route.subnet+" "+route.routeInfo)
+	}
+	for _, key := range sortedKeys(state.rules) {
+		rule := state.rules[key]
+		data.Rules = append(data.Rules, rule.fromSource+" "+rule.routeTable+" "+rule.priority)
+	}
+	for _, key := range sortedKeys(state.snat) {
+		data.SNAT = append(data.SNAT, key)
+	}
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON - Decode the route state from the route state file.  Entries that are not valid are ignored
+func (state *routeState) UnmarshalJSON(bytes []byte) error {
+	data := routeStateJSON{}
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return err
+	}
+	*state = newRouteState()
+	for _, route := range data.Routes {
+		if fields := strings.SplitN(route, " ", 3); len(fields) == 3 {
+			state.addRoute(fields[1], fields[0], fields[2])
+		}
+	}
+	for _, rule := range data.Rules {
+		if fields := strings.Split(rule, " "); len(fields) == 3 {
+			state.addRule(fields[0], fields[1], fields[2])
+		}
+	}
+	for _, snat := range data.SNAT {
+		if fields := strings.Split(snat, " "); len(fields) == 3 {
+			state.addSNAT(fields[0], fields[1], fields[2])
+		}
+	}
+	return nil
+}
+
+// Is the state empty
+func (state routeState) empty() bool {
+	return len(state.routes) == 0 && len(state.rules) == 0 && len(state.snat) == 0
+}
+
+// Entries of the state that are not part of the new state.  Rules are kept if their priority changed, so the
+// priority is updated when the state is changed to the new state
+func (state routeState) staleEntries(newState routeState) routeState {
+	stale := newRouteState()
+	for key, route := range state.routes {
+		if _, ok := newState.routes[key]; !ok {
+			stale.routes[key] = route
+		}
+	}
+	for key, rule := range state.rules {
+		if newRule, ok := newState.rules[key]; !ok || newRule.priority != rule.priority {
+			stale.rules[key] = rule
+		}
+	}
+	for key, snat := range state.snat {
+		if _, ok := newState.snat[key]; !ok {
+			stale.snat[key] = snat
+		}
+	}
+	return stale
+}
+
+// Run the operations of a route plan.  In dry run mode the operations are only logged
+func executeRoutePlan(plan []routeOperation) {
+	for _, op := range plan {
+		if dryRun {
+			log.Printf("DRY RUN: %s", op)
+			continue
+		}
+		switch op.kind {
+		case opRoute:
+			network.UpdateRoute(op.action, op.route.subnet, op.route.routeInfo)
+		case opRule:
+			network.UpdateRouteRule(op.action, op.rule.fromSource, op.rule.routeTable, op.rule.priority)
+		case opNAT:
+			network.ConfigureSNAT(op.owner, op.action, op.snat.remoteGateway, op.snat.vpnPodIP, op.snat.loadBalancerIP)
+		case opNATFlush:
+			network.FlushLocalSubnetNAT(op.owner)
+		case opConntrack:
+			if runtime.GOOS != "darwin" {
+				network.DeleteConntrackEntry(op.snat.remoteGateway, op.snat.loadBalancerIP)
+			}
+		}
+	}
+	if !dryRun {
+		log.Printf("Applied %d route/rule/SNAT changes", len(plan))
+	}
+}
+
+// Have any of the SNAT rules changed between the two states
+func snatChanged(oldState, newState routeState) bool {
+	if len(oldState.snat) != len(newState.snat) {
+		return true
+	}
+	for key := range newState.snat {
+		if _, ok := oldState.snat[key]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort the keys of one of the route state maps, so changes are always made in the same order
+func sortedKeys[T any](entries map[string]T) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
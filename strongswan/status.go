@@ -0,0 +1,252 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/IBM-Cloud/iks-strongswan/kube"
+	"github.com/IBM-Cloud/iks-strongswan/network"
+)
+
+// Various constants
+const (
+	envVarNodeName = "NODE_NAME"
+
+	routeStatusInterval = 30 * time.Second // How often the VPN pod checks the acknowledgements of the route daemons
+)
+
+var nodeName string                                 // Name of the worker node the pod is running on
+var routeDaemonClient *kubernetes.Clientset         // Used by the route daemon to publish the acknowledgements
+var publishedStatus = map[string]kube.RouteStatus{} // Last acknowledgement published for each release
+
+// Verify that the routes, rules and SNAT rules of the release are in place on this node
+func verifyRelease(release routeRelease) error {
+	routeData := release.routeData
+	if routeData.RemoteSubnet == "" || routeData.RouteTable == "" || routeData.WorkerNodeIP == "" || routeData.WorkerSubnet == "" {
+		return nil
+	}
+	if localIP == routeData.WorkerNodeIP && routeData.WorkerNodeIP == routeData.VpnPodIP {
+		return nil // VPN pod is using host networking, no routes or SNAT rules are added on this node
+	}
+	node := currentNode(release)
+	problems := node.unreachableAddresses()
+	state := planRouteState(release, node)
+	for _, routeTable := range state.routeTables() {
+		routes, err := network.GetRoutes(routeTable)
+		if err != nil {
+			return err
+		}
+		for _, expected := range state.tableRoutes(routeTable) {
+			via, dev := routeInfoNextHop(expected.routeInfo)
+			route, found := findRoute(routes, expected.subnet)
+			if !found || (!isMultipath(expected.routeInfo) && (route.Via != via || route.Dev != dev)) {
+				problems = append(problems, fmt.Sprintf("route for %s is missing from table %s", expected.subnet, routeTable))
+			}
+		}
+	}
+	for _, key := range sortedKeys(state.rules) {
+		rule := state.rules[key]
+		if found, err := network.RuleExists(rule.fromSource, rule.routeTable); err != nil || !found {
+			problems = append(problems, fmt.Sprintf("rule from %s lookup %s is missing", rule.fromSource, rule.routeTable))
+		}
+	}
+	for _, key := range sortedKeys(state.snat) {
+		snat := state.snat[key]
+		if network.SNATRuleExists(release.owner, snat.remoteGateway, snat.vpnPodIP, snat.loadBalancerIP) {
+			continue
+		}
+		if snat.vpnPodIP != "" {
+			problems = append(problems, fmt.Sprintf("SNAT rule for %s from %s to %s is missing", snat.remoteGateway, snat.vpnPodIP, snat.loadBalancerIP))
+		} else {
+			problems = append(problems, fmt.Sprintf("MASQUERADE rule for %s is missing", snat.remoteGateway))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Publish the acknowledgement of this node for the release.  Nothing is sent if the result did not change
+func publishRouteStatus(release routeRelease) {
+	status := kube.RouteStatus{Generation: release.routeData.Generation, Result: kube.RouteResultApplied}
+	if dryRun {
+		// Nothing is changed on the node, so the verification would always fail and stop the start up of the VPN pod
+		log.Printf("DRY RUN: Routes of %s were not applied or verified", release.key)
+	} else if err := verifyRelease(release); err != nil {
+		status.Result = kube.RouteResultFailed
+		status.Error = err.Error()
+	}
+	sendRouteStatus(release, status)
+}
+
+// Publish that the routes config map of the release was not applied, since its route data can not be used
+func publishRouteError(release routeRelease, generation string, err error) {
+	sendRouteStatus(release, kube.RouteStatus{Generation: generation, Result: kube.RouteResultFailed, Error: err.Error()})
+}
+
+// Store the status of this node for the release in the status config map
+func sendRouteStatus(release routeRelease, status kube.RouteStatus) {
+	if routeDaemonClient == nil || nodeName == "" {
+		return
+	}
+	if previous, ok := publishedStatus[release.key]; ok && previous.Generation == status.Generation && previous.Result == status.Result && previous.Error == status.Error {
+		return
+	}
+	status.Time = time.Now().UTC().Format(time.RFC3339)
+	status.Subnet = localSubnet
+	log.Printf("Publishing status for %s: generation: %s result: %s %s", release.key, status.Generation, status.Result, status.Error)
+	if err := kube.PublishRouteStatus(routeDaemonClient, release.namespace, kube.StatusConfigMapName(release.releaseName), nodeName, status); err != nil {
+		log.Printf("WARNING: %v", err)
+		return
+	}
+	publishedStatus[release.key] = status
+}
+
+// Remove the acknowledgement of this node for the release
+func removeRouteStatus(release routeRelease) {
+	delete(publishedStatus, release.key)
+	if routeDaemonClient == nil || nodeName == "" {
+		return
+	}
+	if err := kube.RemoveRouteStatus(routeDaemonClient, release.namespace, kube.StatusConfigMapName(release.releaseName), nodeName); err != nil {
+		log.Printf("WARNING: %v", err)
+	}
+}
+
+// Periodically log which worker nodes have applied the current generation of the routes config map.  The generation
+// changes when the tunnel state is published
+func routeStatusThread(kubectl *kubernetes.Clientset) {
+	lastSummary := ""
+	for {
+		generation := currentRouteGeneration()
+		statusMap, err := kube.GetRouteStatus(kubectl, namespace, kube.StatusConfigMapName(releaseName))
+		if err != nil {
+			log.Printf("WARNING: %v", err)
+			time.Sleep(routeStatusInterval)
+			continue
+		}
+		applyWorkerSubnet(kubectl, statusMap[nodeName].Subnet)
+		if summary := summarizeRouteStatus(statusMap, generation); summary != lastSummary {
+			log.Printf("Route daemon status for generation %s: %s", generation, summary)
+			for _, node := range sortedNodes(statusMap) {
+				if status := statusMap[node]; status.State(generation) != kube.RouteStateInSync {
+					log.Printf("   %s: %s generation: %s %s", node, status.State(generation), status.Generation, status.Error)
+				}
+			}
+			lastSummary = summary
+		}
+		time.Sleep(routeStatusInterval)
+	}
+}
+
+// The VPN pod is not in the network namespace of the worker node, so some pod networks can not determine the subnet
+// of the node from the VPN pod.  The subnet published by the route daemon on the node replaces the one in the routes
+// config map, so the route daemons on the other nodes in the subnet do not use onlink routes
+func applyWorkerSubnet(kubectl *kubernetes.Clientset, subnet string) {
+	if _, _, err := net.ParseCIDR(subnet); err != nil {
+		return
+	}
+	routeDataMutex.Lock()
+	defer routeDataMutex.Unlock()
+	if subnet == routeConfigMapData.WorkerSubnet {
+		return
+	}
+	log.Printf("Route daemon on worker node %s reported subnet %s, replacing %s", nodeName, subnet, routeConfigMapData.WorkerSubnet)
+	routeData := routeConfigMapData
+	routeData.WorkerSubnet = subnet
+	generation, err := kube.UpdateConfigMap(kubectl, namespace, configMapName, routeData)
+	if err != nil {
+		log.Printf("ERROR: Unable to publish the worker subnet: %v", err)
+		return
+	}
+	routeConfigMapData = routeData
+	routeGeneration = generation
+	log.Printf("   config map generation: %v", routeGeneration)
+}
+
+// Count the number of nodes in each sync state
+func summarizeRouteStatus(statusMap map[string]kube.RouteStatus, generation string) string {
+	counts := map[string]int{}
+	for _, status := range statusMap {
+		counts[status.State(generation)]++
+	}
+	return fmt.Sprintf("%d %s, %d %s, %d %s", counts[kube.RouteStateInSync], kube.RouteStateInSync,
+		counts[kube.RouteStateStale], kube.RouteStateStale, counts[kube.RouteStateFailing], kube.RouteStateFailing)
+}
+
+// Sort the node names of the status map
+func sortedNodes(statusMap map[string]kube.RouteStatus) []string {
+	nodes := make([]string, 0, len(statusMap))
+	for node := range statusMap {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Display the sync state of each worker node: "strongswan status".  Exit code is 1 if any node is not in sync
+func runStatusCommand() {
+	namespace = os.Getenv(envVarNamespace)
+	releaseName = os.Getenv(envVarReleaseName)
+	if namespace == "" || releaseName == "" {
+		log.Fatalf("ERROR: Required environment variables %s and %s were not specified", envVarNamespace, envVarReleaseName)
+	}
+	kubectl := kube.GetClient()
+	generation, err := kube.GetRouteGeneration(kubectl, namespace, releaseName+"-strongswan-routes")
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	statusMap, err := kube.GetRouteStatus(kubectl, namespace, kube.StatusConfigMapName(releaseName))
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	nodes, err := kube.GetNodeNames(kubectl)
+	if err != nil {
+		log.Printf("WARNING: Unable to list the worker nodes: %v", err)
+	}
+	for _, node := range nodes {
+		if _, ok := statusMap[node]; !ok {
+			statusMap[node] = kube.RouteStatus{}
+		}
+	}
+
+	fmt.Printf("Routes config map generation: %s\n\n", generation)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "NODE\tSTATE\tGENERATION\tTIME\tERROR")
+	allInSync := true
+	for _, node := range sortedNodes(statusMap) {
+		status := statusMap[node]
+		state := kube.RouteStateMissing
+		if status.Result != "" {
+			state = status.State(generation)
+		}
+		if state != kube.RouteStateInSync {
+			allInSync = false
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", node, state, status.Generation, status.Time, status.Error)
+	}
+	writer.Flush() // #nosec G104 ok to ignore error on flush of stdout
+	if !allInSync {
+		os.Exit(1)
+	}
+}
@@ -11,6 +11,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net"
 	"os"
@@ -19,12 +20,14 @@ import (
 	"strings"
 	"time"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 
-	"github.com/IBM-Cloud/iks-strongswan/calico"
+	"github.com/IBM-Cloud/iks-strongswan/cni"
 	"github.com/IBM-Cloud/iks-strongswan/kube"
 	"github.com/IBM-Cloud/iks-strongswan/monitoring"
 	"github.com/IBM-Cloud/iks-strongswan/network"
+	"github.com/IBM-Cloud/iks-strongswan/platform"
 	"github.com/IBM-Cloud/iks-strongswan/utils"
 )
 
@@ -36,6 +39,10 @@ const (
 	envVarEnableSingleIP   = "ENABLE_SINGLE_IP"
 	envVarLoadBalancerIP   = "LOAD_BALANCER_IP"
 	envVarLocalZoneSubnet  = "LOCAL_ZONE_SUBNET"
+	envVarNodePublicIP     = "NODE_PUBLIC_IP_SOURCES"
+	envVarRouteDaemonWait  = "ROUTE_DAEMON_TIMEOUT"
+	envVarRouteNamespaces  = "ROUTE_NAMESPACES"
+	envVarRoutePodSelector = "ROUTE_POD_SELECTOR"
 	envVarServiceName      = "SERVICE_NAME"
 	envVarZoneLoadBalancer = "ZONE_LOAD_BALANCER"
 
@@ -48,8 +55,9 @@ const (
 	charonloggingConf = "charon-logging.conf"
 )
 
-var cleanupCalico []string
+var cleanupSNAT []string // Subnets that the pod SNAT was disabled for
 var connectUsingVip bool
+var connectUsingVipPending bool // connectUsingLoadBalancerIP waits for the load balancer IP to be assigned
 var enablePodSNAT string
 var enableSingleIP bool
 var ipsecAuto string
@@ -58,11 +66,15 @@ var leftSubnet string
 var loadBalancerIP string
 var localZoneSubnet string
 var monitoringEnabled bool
+var nodePublicIPSources []kube.NodeIPSource // Where the public IP of the worker node is read from
 var remoteGateway string
 var requestedLoadBalancerIP string
 var rightSubnet string
+var routeConfigMapData kube.RouteData // Routing data written to the routes config map by the VPN pod
+var routeGeneration string            // Generation of the routes config map written by the VPN pod
+var routeNamespaces string            // Only pods in these namespaces use the VPN
+var routePodSelector string           // Only pods matching this label selector use the VPN
 var serviceName string
-var tcpListener *net.TCPListener
 var zoneLoadBalancer string
 
 var establishedMap = map[string]string{} // Keep track of IKE_SA connections
@@ -73,6 +85,9 @@ func parseStrongswanOutput(line string) {
 		words := strings.Fields(line)
 		establishedMap[words[2]] = time.Now().Format("01/02_15:04:05") // Similar format that is shown in log (no year or spaces)
 		log.Printf("ESTABLISHED: %v", establishedMap)
+		if tunnelGroup != "" && len(establishedMap) == 1 {
+			tunnelEstablishedChanged(true)
+		}
 		if monitoringEnabled && len(establishedMap) == 1 {
 			log.Print("Monitoring of vpn tunnel has started")
 			monitoring.Start()
@@ -82,6 +97,9 @@ func parseStrongswanOutput(line string) {
 		words := strings.Fields(line)
 		delete(establishedMap, words[2])
 		log.Printf("ESTABLISHED: %v", establishedMap)
+		if tunnelGroup != "" && len(establishedMap) == 0 {
+			tunnelEstablishedChanged(false)
+		}
 		if monitoringEnabled && len(establishedMap) == 0 {
 			log.Print("Monitoring of vpn tunnel has stopped")
 			monitoring.Stop()
@@ -159,6 +177,20 @@ func vpnPodInit() {
 	requestedLoadBalancerIP = os.Getenv(envVarLoadBalancerIP)
 	zoneLoadBalancer = os.Getenv(envVarZoneLoadBalancer)
 	localZoneSubnet = os.Getenv(envVarLocalZoneSubnet)
+	nonClusterSubnet = validateNonClusterSubnet(os.Getenv(envVarNonClusterSubnet))
+	sources, err := kube.ParseNodeIPSources(os.Getenv(envVarNodePublicIP))
+	if err != nil {
+		log.Fatalf("ERROR: Invalid value specified for %s: %v", envVarNodePublicIP, err)
+	}
+	nodePublicIPSources = sources
+	initRouteDaemonTimeout()
+	routeNamespaces = strings.ReplaceAll(os.Getenv(envVarRouteNamespaces), " ", "")
+	routePodSelector = os.Getenv(envVarRoutePodSelector)
+	if _, err := labels.Parse(routePodSelector); err != nil {
+		log.Fatalf("ERROR: Invalid value specified for %s: %v", envVarRoutePodSelector, err)
+	}
+	initTunnelGroup()
+	initExposure()
 
 	// Copy the configuration files to the correct locations
 	utils.CopyConfigFile(ipsecConf, ipsecConfigDir, ipsecEtcDir, true)
@@ -192,22 +224,47 @@ func vpnPodConfig(kubectl *kubernetes.Clientset) {
 	}
 	log.Printf("   vpn pod name: %v", vpnPodName)
 
-	vpnPodIP, workerNodeIP := kube.GetPodInfo(kubectl, namespace, vpnPodName)
+	vpnPodIP, workerNodeIP, err := kube.GetPodInfo(kubectl, namespace, vpnPodName)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	nodeName = os.Getenv(envVarNodeName)
+	if nodeName == "" {
+		nodeName = workerNodeIP // Node name is the worker node IP on IKS, otherwise the node is found by its address
+	}
 	log.Printf("   vpn pod ip: %v", vpnPodIP)
 	log.Printf("   worker node private ip: %v", workerNodeIP)
+	initPlatform(kubectl, nodeName, workerNodeIP)
 	validateIPNotInRemoteSubnet(vpnPodIP, rightSubnet)
 	validateIPNotInRemoteSubnet(workerNodeIP, rightSubnet)
 
+	// In a dual stack cluster, the IPv6 addresses are used to route the IPv6 remote subnets
+	vpnPodIPv6, workerNodeIPv6, err := kube.GetPodIPv6Info(kubectl, namespace, vpnPodName)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if vpnPodIPv6 != "" {
+		log.Printf("   vpn pod ipv6: %v", vpnPodIPv6)
+		log.Printf("   worker node private ipv6: %v", workerNodeIPv6)
+	}
+
 	nodePublicIP := ""
 	// Don't bother extracting node public IP unless required
-	if leftID == utils.LeftIDNodePublicIP {
-		nodePublicIP = kube.GetNodePublicIP(kubectl, workerNodeIP)
+	if leftID == utils.LeftIDNodePublicIP || exposureMode == exposureNodePort || exposureMode == exposureHostNetwork {
+		nodePublicIP, err = platform.Get().NodePublicIP(kubectl, nodeName, workerNodeIP, nodePublicIPSources)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
 		log.Printf("   worker node public ip: %v", nodePublicIP)
 	}
 
 	// If ZONE_LOAD_BALANCER was configured, adjust the serviceName and loadBalancer based on worker node zone
-	if zoneLoadBalancer != "" || localZoneSubnet != "" {
-		zone := kube.GetNodeZone(kubectl, workerNodeIP)
+	zone := ""
+	if zoneLoadBalancer != "" || localZoneSubnet != "" || zoneLocalRouting {
+		zone, err = platform.Get().NodeZone(kubectl, nodeName, workerNodeIP)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
 		log.Printf("   worker node zone: %v", zone)
 		if zoneLoadBalancer != "" {
 			serviceName, requestedLoadBalancerIP = processZoneLoadBalancer(zone)
@@ -217,39 +274,77 @@ func vpnPodConfig(kubectl *kubernetes.Clientset) {
 		}
 	}
 
-	loadBalancerIP = kube.GetLoadBalancerIP(kubectl, namespace, serviceName, requestedLoadBalancerIP, ipsecAuto, connectUsingVip)
+	// The load balancer IP can only be the source of the VPN connection if it is bound on the worker nodes
+	if connectUsingVip && !platform.Get().LoadBalancerSourceIP() {
+		log.Printf("WARNING: connectUsingLoadBalancerIP is not supported on platform %s and is ignored", platform.Get().Name())
+		connectUsingVip = false
+	}
+	loadBalancerIP, err = getExposureIP(kubectl, nodePublicIP)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
 	log.Printf("   load balancer ip: %v", loadBalancerIP)
-	if loadBalancerIP == "<pending>" {
+	if loadBalancerIP == "<pending>" && connectUsingVip {
+		log.Printf("WARNING: Load balancer IP is not assigned, connectUsingLoadBalancerIP is enabled once it is assigned")
 		connectUsingVip = false
+		connectUsingVipPending = true
 	}
-	routeTable := kube.CalculateRouterTable(loadBalancerIP)
-	log.Printf("   route table: %v", routeTable)
+	loadBalancerIPv6 := ""
+	if network.IsIPv6(remoteGateway) && exposureMode == exposureLoadBalancer {
+		loadBalancerIPv6, err = kube.GetLoadBalancerIPv6(kubectl, namespace, serviceName)
+		if err != nil {
+			log.Printf("WARNING: Unable to determine the IPv6 address of the load balancer: %v", err)
+		}
+		log.Printf("   load balancer ipv6: %v", loadBalancerIPv6)
+	}
+	// Reserve route tables that are not used by any other strongSwan release in the cluster
+	tables, err := kube.AllocateRouteTables(kubectl, namespace, releaseName, kube.CalculateRouterTable(loadBalancerIP))
+	if err != nil {
+		log.Printf("WARNING: Unable to allocate route tables from %s/%s: %v", kube.RegistryNamespace, kube.RegistryName, err)
+		tables = kube.TableAllocation{RouteTable: kube.CalculateRouterTable(loadBalancerIP)}
+	}
+	log.Printf("   route table: %v", tables.RouteTable)
+	log.Printf("   tunnel route table: %v", tables.TunnelTable)
+	log.Printf("   rule priority: %v", tables.RulePriority)
 
-	// Update the ipsec.conf leftid and leftsubnet values if necessary
+	// Update the ipsec.conf leftid and leftsubnet values if necessary.  A load balancer IP that is not assigned yet is
+	// set in the leftid once the watch of the load balancer service delivers it
 	configFile := filepath.Join(ipsecEtcDir, ipsecConf)
-	utils.UpdateConfigLeftID(configFile, leftID, nodePublicIP, loadBalancerIP)
+	if leftID == utils.LeftIDLoadBalancerIP && loadBalancerIP == "<pending>" {
+		log.Printf("WARNING: Load balancer IP is not assigned, local.id is set once it is assigned")
+		appliedLeftID = leftID
+	} else {
+		utils.UpdateConfigLeftID(configFile, leftID, nodePublicIP, loadBalancerIP)
+		appliedLeftID = loadBalancerIP
+	}
 	utils.UpdateConfigLeftSubnet(configFile, leftSubnet, localZoneSubnet)
 	if leftSubnet == utils.LeftSubnetZoneSpecific {
 		leftSubnet = localZoneSubnet
 	}
 
-	workerSubnet := calico.GetNodeSubnet(workerNodeIP)
+	workerSubnet, err := cni.Get().NodeSubnet(workerNodeIP)
+	if err != nil {
+		log.Fatalf("ERROR: Failed to retrieve the subnet of the worker node: %v", err)
+	}
 	log.Printf("   worker subnet: %v", workerSubnet)
 
-	vpnPodDevice := calico.GetPodInterface()
+	vpnPodDevice, err := cni.Get().PodInterface(namespace, vpnPodName)
+	if err != nil {
+		log.Fatalf("ERROR: Failed to retrieve the interface of the VPN pod: %v", err)
+	}
 	log.Printf("   vpn pod device name: %v", vpnPodDevice)
 
 	// Initialize the monitoring logic if enabled
 	if monitoringEnabled {
-		clusterID := kube.GetClusterID(kubectl)
+		clusterID := platform.Get().ClusterID(kubectl)
 		monitoring.Init(vpnPodName, clusterID)
 	}
 
 	// Apply subnet NAT tables inside of the VPN pod
 	if localSubnetNAT != "" {
-		network.ConfigureSubnetNAT(localSubnetNAT, rightSubnet)
+		network.ConfigureSubnetNAT(releaseName, localSubnetNAT, rightSubnet)
 	} else if enableSingleIP {
-		network.ConfigureSingleSourceIP(leftSubnet, rightSubnet)
+		network.ConfigureSingleSourceIP(releaseName, leftSubnet, rightSubnet)
 	}
 	if remoteSubnetNAT != "" {
 		origSubnets := leftSubnet
@@ -272,7 +367,7 @@ func vpnPodConfig(kubectl *kubernetes.Clientset) {
 			// If enableSingleIP is enabled, then the "untranslated" leftSubnet is "any traffic"
 			origSubnets = "0.0.0.0/0"
 		}
-		network.ConfigureSubnetNAT(remoteSubnetNAT, additionalEntries+origSubnets)
+		network.ConfigureSubnetNAT(releaseName, remoteSubnetNAT, additionalEntries+origSubnets)
 	}
 
 	// If "auto" was specified for enablePodSNAT, then translate it to true/false now that we know the vpnPodIP
@@ -280,7 +375,7 @@ func vpnPodConfig(kubectl *kubernetes.Clientset) {
 		enablePodSNAT = strconv.FormatBool(!network.IsAddrInSubnet(vpnPodIP, leftSubnet))
 	}
 
-	// Create/update the calico IPPool for each remote subnet
+	// Disable the SNAT of pod traffic to each remote subnet (calico IPPool)
 	if enablePodSNAT == "false" {
 		poolSubnets := rightSubnet
 		// If there is NAT, we need to translate the subnets before we create the pools
@@ -294,74 +389,96 @@ func vpnPodConfig(kubectl *kubernetes.Clientset) {
 
 		// Create the required IPPools
 		for _, subnet := range strings.Split(poolSubnets, ",") {
-			log.Printf("   creating IPPool for subnet: %v", subnet)
-			calico.CreateIPPool(subnet)
-			cleanupCalico = append(cleanupCalico, subnet)
+			log.Printf("   disabling pod SNAT for subnet: %v", subnet)
+			if err := cni.Get().DisableSNAT(subnet); err != nil {
+				log.Fatalf("ERROR: Failed to disable pod SNAT for %s: %v", subnet, err)
+			}
+			cleanupSNAT = append(cleanupSNAT, subnet)
 		}
 	}
 
 	// If we are forcing outbound traffic through the LoadBalancer VIP
 	if connectUsingVip {
-		log.Printf("   creating IPPool for remote gateway: %v", remoteGateway)
-		calico.CreateIPPool(remoteGateway + "/32")
-		cleanupCalico = append(cleanupCalico, remoteGateway+"/32")
-
-		log.Print("   creating a TCP listener so that route daemon can inform us when SNAT rule is in place")
-		addr := net.TCPAddr{Port: 4500}
-		var err error
-		tcpListener, err = net.ListenTCP("tcp", &addr)
-		if err != nil {
-			log.Fatalf("ERROR: Failed to create listener: %v", err)
+		if err := disableGatewaySNAT(); err != nil {
+			log.Fatalf("ERROR: %v", err)
 		}
 	}
 
 	// Update the config map
 	configMapData := kube.RouteData{
-		ConnectUsingLB: strconv.FormatBool(connectUsingVip),
-		LoadBalancerIP: loadBalancerIP,
-		LocalSubnet:    leftSubnet,
-		RemoteGateway:  remoteGateway,
-		RemoteSubnet:   rightSubnet,
-		RouteTable:     routeTable,
-		VpnPodDevice:   vpnPodDevice,
-		VpnPodIP:       vpnPodIP,
-		VpnPodName:     vpnPodName,
-		WorkerNodeIP:   workerNodeIP,
-		WorkerSubnet:   workerSubnet,
+		ConnectUsingLB:   strconv.FormatBool(connectUsingVip),
+		LoadBalancerIP:   loadBalancerIP,
+		LoadBalancerIPv6: loadBalancerIPv6,
+		LocalSubnet:      leftSubnet,
+		LocalSubnetNAT:   localSubnetNAT,
+		NonClusterSubnet: nonClusterSubnet,
+		RemoteGateway:    remoteGateway,
+		RemoteSubnet:     rightSubnet,
+		RemoteSubnetNAT:  remoteSubnetNAT,
+		RouteNamespaces:  routeNamespaces,
+		RoutePodSelector: routePodSelector,
+		RouteTable:       tables.RouteTable,
+		RulePriority:     tables.RulePriority,
+		TunnelGroup:      tunnelGroup,
+		TunnelGroupMode:  tunnelGroupMode,
+		TunnelPriority:   tunnelPriority,
+		TunnelState:      initialTunnelState(),
+		TunnelTable:      tables.TunnelTable,
+		TunnelWeight:     tunnelWeight,
+		VpnPodDevice:     vpnPodDevice,
+		VpnPodIP:         vpnPodIP,
+		VpnPodIPv6:       vpnPodIPv6,
+		VpnPodName:       vpnPodName,
+		WorkerNodeIP:     workerNodeIP,
+		WorkerNodeIPv6:   workerNodeIPv6,
+		WorkerSubnet:     workerSubnet,
+		Zone:             zone,
+		ZoneLocalRouting: strconv.FormatBool(zoneLocalRouting),
 	}
 	log.Printf("   updating config map: %v", configMapName)
-	kube.UpdateConfigMap(kubectl, namespace, configMapName, configMapData)
+	routeConfigMapData = configMapData
+	routeGeneration, err = kube.UpdateConfigMap(kubectl, namespace, configMapName, configMapData)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	log.Printf("   config map generation: %v", routeGeneration)
 }
 
-// Perform any cleanup necessary of the VPN pod
-func vpnPodWaitRouteDaemon() {
-	if !connectUsingVip {
-		return
+// Disable the SNAT of pod traffic to the remote gateway, so the route daemon can SNAT it to the load balancer IP
+func disableGatewaySNAT() error {
+	gatewayPool := remoteGateway + "/32"
+	if network.IsIPv6(remoteGateway) {
+		gatewayPool = remoteGateway + "/128"
 	}
-
-	// Wait for route daemon to connect to us
-	log.Print("Wait for the route daemon set to apply iptable rules on this worker node")
-	conn, err := tcpListener.AcceptTCP()
-	if err != nil {
-		log.Fatalf("ERROR: Failed to wait for incoming connection: %v", err)
+	for _, subnet := range cleanupSNAT {
+		if subnet == gatewayPool {
+			return nil // Already disabled, the load balancer IP update is being retried
+		}
 	}
-
-	// Close done the incoming connection and the listener
-	log.Print("Incoming connection received.  Continue with VPN pod start up logic")
-	conn.Close()        // #nosec G104 ok to ignore error on close
-	tcpListener.Close() // #nosec G104 ok to ignore error on close
+	log.Printf("   disabling pod SNAT for remote gateway: %v", remoteGateway)
+	if err := cni.Get().DisableSNAT(gatewayPool); err != nil {
+		return fmt.Errorf("failed to disable pod SNAT for %s: %v", gatewayPool, err)
+	}
+	cleanupSNAT = append(cleanupSNAT, gatewayPool)
+	return nil
 }
 
 // Perform any cleanup necessary of the VPN pod
 func vpnPodCleanup() {
-	if len(cleanupCalico) > 0 {
-		log.Print("Clean up resources allocated in calico")
-		for _, subnet := range cleanupCalico {
-			log.Printf("   deleting IPPool for subnet: %v", subnet)
-			calico.DeleteIPPool(subnet)
+	if tunnelGroup != "" {
+		log.Print("Mark the tunnel as down, so the route daemons move the routes to the other tunnels of the group")
+		tunnelEstablishedChanged(false)
+	}
+	if len(cleanupSNAT) > 0 {
+		log.Printf("Clean up resources allocated in the %s pod network", cni.Get().Name())
+		for _, subnet := range cleanupSNAT {
+			log.Printf("   restoring pod SNAT for subnet: %v", subnet)
+			if err := cni.Get().RestoreSNAT(subnet); err != nil {
+				log.Printf("ERROR: %v", err)
+			}
 		}
-		cleanupCalico = []string{}
-		log.Print("Successfully cleaned up calico")
+		cleanupSNAT = []string{}
+		log.Print("Successfully cleaned up the pod network")
 	}
 	if monitoringEnabled {
 		log.Print("Cancel the monitor thread")
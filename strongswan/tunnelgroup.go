@@ -0,0 +1,148 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Kubernetes Service, 5737-D43
+ * (C) Copyright IBM Corp. 2026 All Rights Reserved.
+ * The source code for this program is not published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/IBM-Cloud/iks-strongswan/kube"
+	"github.com/IBM-Cloud/iks-strongswan/monitoring"
+)
+
+// Various constants
+const (
+	envVarTunnelGroup     = "TUNNEL_GROUP"
+	envVarTunnelGroupMode = "TUNNEL_GROUP_MODE"
+	envVarTunnelPriority  = "TUNNEL_PRIORITY"
+	envVarTunnelWeight    = "TUNNEL_WEIGHT"
+	envVarZoneLocal       = "ZONE_LOCAL_ROUTING"
+
+	defaultTunnelPriority = "100"
+	defaultTunnelWeight   = "1"
+)
+
+var tunnelGroup string                  // Releases in the same tunnel group are redundant tunnels to the same remote subnets
+var tunnelGroupMode string              // failover or ecmp
+var tunnelPriority string               // Lowest priority is the preferred tunnel of the group
+var tunnelWeight string                 // Relative share of the traffic with ecmp
+var zoneLocalRouting bool               // Route daemons prefer the tunnel of the group in their own zone
+var tunnelEstablished bool              // At least one IKE SA is established
+var tunnelHealthy bool                  // Result of the monitoring tests
+var tunnelKubectl *kubernetes.Clientset // Client used to publish the tunnel state
+var routeDataMutex sync.Mutex           // Serialize updates of the routes config map after start up
+
+// Read and validate the tunnel group settings of the VPN pod
+func initTunnelGroup() {
+	tunnelGroup = os.Getenv(envVarTunnelGroup)
+	tunnelGroupMode = strings.ToLower(os.Getenv(envVarTunnelGroupMode))
+	if tunnelGroupMode == "" {
+		tunnelGroupMode = kube.TunnelGroupFailover
+	}
+	if tunnelGroupMode != kube.TunnelGroupFailover && tunnelGroupMode != kube.TunnelGroupECMP {
+		log.Fatalf("ERROR: Invalid value specified for %s: %s", envVarTunnelGroupMode, tunnelGroupMode)
+	}
+	tunnelPriority = validateTunnelNumber(envVarTunnelPriority, defaultTunnelPriority, 0)
+	tunnelWeight = validateTunnelNumber(envVarTunnelWeight, defaultTunnelWeight, 1)
+	if tunnelGroup == "" {
+		return
+	}
+	zoneLocalRouting = strings.ToLower(os.Getenv(envVarZoneLocal)) == "true"
+	log.Printf("Tunnel group: %s mode: %s priority: %s weight: %s zone local: %v", tunnelGroup, tunnelGroupMode, tunnelPriority, tunnelWeight, zoneLocalRouting)
+}
+
+// Validate one of the numeric tunnel group settings
+func validateTunnelNumber(envVar, defaultValue string, minimum int) string {
+	value := strings.TrimSpace(os.Getenv(envVar))
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < minimum || (envVar == envVarTunnelWeight && number > 256) {
+		log.Fatalf("ERROR: Invalid value specified for %s: %s", envVar, value)
+	}
+	return strconv.Itoa(number)
+}
+
+// Initial tunnel state written to the routes config map.  Tunnels that are part of a group start out down, so
+// the route daemons do not move the routes to this tunnel before it is established
+func initialTunnelState() string {
+	if tunnelGroup == "" {
+		return ""
+	}
+	return kube.TunnelStateDown
+}
+
+// Start publishing the tunnel state once the VPN pod has been configured
+func startTunnelStateUpdates(kubectl *kubernetes.Clientset) {
+	if tunnelGroup == "" || disableRouting {
+		return
+	}
+	tunnelKubectl = kubectl
+	if monitoringEnabled {
+		monitoring.SetHealthHandler(tunnelHealthChanged)
+	}
+}
+
+// IKE SA was established or deleted
+func tunnelEstablishedChanged(established bool) {
+	routeDataMutex.Lock()
+	defer routeDataMutex.Unlock()
+	tunnelEstablished = established
+	publishTunnelState()
+}
+
+// Result of the monitoring tests changed
+func tunnelHealthChanged(healthy bool) {
+	routeDataMutex.Lock()
+	defer routeDataMutex.Unlock()
+	tunnelHealthy = healthy
+	publishTunnelState()
+}
+
+// Write the tunnel state to the routes config map if it has changed.  The tunnel is up when an IKE SA is
+// established and, if monitoring is enabled, the monitoring tests are passing.  Caller must hold routeDataMutex
+func publishTunnelState() {
+	if tunnelKubectl == nil {
+		return
+	}
+	state := kube.TunnelStateDown
+	if tunnelEstablished && (!monitoringEnabled || tunnelHealthy) {
+		state = kube.TunnelStateUp
+	}
+	if state == routeConfigMapData.TunnelState {
+		return
+	}
+	log.Printf("Tunnel state of %s in tunnel group %s changed to: %s", releaseName, tunnelGroup, state)
+	routeData := routeConfigMapData
+	routeData.TunnelState = state
+	generation, err := kube.UpdateConfigMap(tunnelKubectl, namespace, configMapName, routeData)
+	if err != nil {
+		// The state is published again on the next change of the IKE SA or monitoring result
+		log.Printf("ERROR: Unable to publish the tunnel state: %v", err)
+		return
+	}
+	routeConfigMapData = routeData
+	routeGeneration = generation
+	log.Printf("   config map generation: %v", routeGeneration)
+}
+
+// Get the current generation of the routes config map
+func currentRouteGeneration() string {
+	routeDataMutex.Lock()
+	defer routeDataMutex.Unlock()
+	return routeGeneration
+}
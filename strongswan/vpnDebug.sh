@@ -181,7 +181,7 @@ else
         echo "Listing the calico IPPool resources:"
         echo
         foundMissing=false
-        calicoCmd getIPPool | tee /tmp/ippool.output
+        /tmp/kubectl get ippools.crd.projectcalico.org -o custom-columns=NAME:.metadata.name,CIDR:.spec.cidr,DISABLED:.spec.disabled | tee /tmp/ippool.output
         IFS=','
         for subnet in $remoteSubnet; do
             if ! grep -q "$subnet" /tmp/ippool.output; then
@@ -197,10 +197,10 @@ else
 
     echo "Displaying the contents of the routes config map:"
     echo
-    /tmp/kubectl get cm -n "$NAMESPACE" "$RELEASE_NAME"-strongswan-routes -o jsonpath='{ .data }' | tr -d '"' | tr '{[ ]},' '\n' | grep ":" | tee /tmp/configmap.routes
+    /tmp/kubectl get cm -n "$NAMESPACE" "$RELEASE_NAME"-strongswan-routes -o jsonpath='{ .data.routes\.json }' | tr -d '"' | tr '{[ ]},' '\n' | grep ":" | tee /tmp/configmap.routes
     echo
     echo "This config map tells the daemon set what routes need to be created"
-    echo "VERIFY: Each field in the 'data:' section is set to the correct value"
+    echo "VERIFY: Each field of the routes.json document is set to the correct value"
     echo "----------------------------------------------------------------------"
 
     echo "Displaying the status of the VPN pod:"
@@ -233,15 +233,21 @@ else
     /tmp/kubectl exec -n "$NAMESPACE" "$vpnNodeDaemon" -- sudo /sbin/ip rule list
 
     echo
-    echo "Displaying the cross-subnet routes located in routing table 199:"
+    tunnelTable=$(grep "tunnelTable:" /tmp/configmap.routes | cut -d":" -f2)
+    tunnelTable=${tunnelTable:-199}
+    echo "Displaying the cross-subnet routes located in routing table $tunnelTable:"
     echo
-    /tmp/kubectl exec -n "$NAMESPACE" "$vpnNodeDaemon" -- sudo /sbin/ip route list table 199
+    /tmp/kubectl exec -n "$NAMESPACE" "$vpnNodeDaemon" -- sudo /sbin/ip route list table "$tunnelTable"
 
     if [ -n  "$LOCAL_SUBNET_NAT" ]; then
         echo "----------------------------------------------------------------------"
         echo "Displaying the local subnet NAT table on the VPN node:"
         echo
-        sudo /usr/sbin/iptables-legacy --list -t nat
+        case "$FIREWALL_BACKEND" in
+            nftables) sudo /usr/sbin/nft list table ip strongswan ;;
+            iptables-nft) sudo /usr/sbin/iptables-nft-save -t nat ;;
+            *) sudo /usr/sbin/iptables-legacy-save -t nat ;;
+        esac
     fi
 fi
 
@@ -2,7 +2,7 @@
  * IBM Confidential
  * OCO Source Materials
  * IBM Cloud Kubernetes Service, 5737-D43
- * (C) Copyright IBM Corp. 2017, 2021 All Rights Reserved.
+ * (C) Copyright IBM Corp. 2017, 2026 All Rights Reserved.
  * The source code for this program is not published or otherwise divested of
  * its trade secrets, irrespective of what has been deposited with
  * the U.S. Copyright Office.
@@ -241,6 +241,16 @@ func UpdateConfigLeftID(filename, leftID, publicIP, loadBalancerIP string) {
 	updateConfigFile(filename, "leftid", leftID, newLeftID)
 }
 
+// ReplaceConfigLeftID - Replace the IP address in the leftid property of the config file that was set by
+// UpdateConfigLeftID, used when the IP address changes after the config file was updated
+func ReplaceConfigLeftID(filename, existingIP, newIP string) error {
+	if net.ParseIP(newIP) == nil {
+		return fmt.Errorf("new local.id value is not an IP address: %s", newIP)
+	}
+	updateConfigFile(filename, "leftid", existingIP, newIP)
+	return nil
+}
+
 // UpdateConfigLeftSubnet - Update the leftsubnet property in the config file if needed
 func UpdateConfigLeftSubnet(filename, leftSubnet, zoneSubnet string) {
 	// Only update the config file if leftsubnet is set to special value